@@ -63,22 +63,62 @@ func main() {
 		{
 			// Config
 			protected.GET("/config", handlers.GetConfig(db))
+			protected.GET("/settings", handlers.GetSettings(db))
+			protected.PUT("/settings", handlers.UpdateSettings(db))
 
 			// Products
 			protected.GET("/products", handlers.GetProducts(db))
+			protected.GET("/products/lookup", handlers.LookupProduct(db))
+			protected.GET("/products/availability", handlers.GetMenuAvailability(db))
 			protected.GET("/products/:id", handlers.GetProduct(db))
 			protected.POST("/products", handlers.CreateProduct(db))
 			protected.PUT("/products/:id", handlers.UpdateProduct(db))
 			protected.DELETE("/products/:id", handlers.DeleteProduct(db))
 			protected.PATCH("/products/:id/stock", handlers.UpdateStock(db))
 			protected.POST("/products/bulk-stock", handlers.BulkUpdateStock(db))
+			protected.GET("/products/:id/variants", handlers.GetProductVariants(db))
+			protected.POST("/products/:id/variants", handlers.CreateProductVariant(db))
+			protected.POST("/products/:id/variants/generate", handlers.GenerateProductVariants(db))
+			protected.POST("/products/:id/barcodes", handlers.AddProductBarcode(db))
+			protected.DELETE("/products/:id/barcodes/:barcodeId", handlers.DeleteProductBarcode(db))
+			protected.GET("/products/:id/units", handlers.GetProductUnits(db))
+			protected.POST("/products/:id/units", handlers.CreateProductUnit(db))
+			protected.PUT("/products/:id/units/:unitId", handlers.UpdateProductUnit(db))
+			protected.DELETE("/products/:id/units/:unitId", handlers.DeleteProductUnit(db))
+			protected.GET("/products/:id/modifier-groups", handlers.GetProductModifierGroups(db))
+			protected.PUT("/products/:id/modifier-groups", handlers.SetProductModifierGroups(db))
+			protected.GET("/products/:id/recipe", handlers.GetProductRecipe(db))
+			protected.PUT("/products/:id/recipe", handlers.SetProductRecipe(db))
+			protected.PATCH("/products/:id/sold-out", handlers.SetSoldOut(db))
+			protected.GET("/products/:id/availability", handlers.GetProductAvailability(db))
+			protected.PUT("/products/:id/availability", handlers.SetProductAvailability(db))
+			protected.GET("/menu/stream", handlers.StreamMenuAvailability(db))
+			protected.GET("/products/:id/price", handlers.GetProductPrice(db))
+			protected.GET("/products/:id/price-tiers", handlers.GetPriceTiers(db))
+			protected.POST("/products/:id/price-tiers", handlers.CreatePriceTier(db))
+			protected.PUT("/products/:id/price-tiers/:tierId", handlers.UpdatePriceTier(db))
+			protected.DELETE("/products/:id/price-tiers/:tierId", handlers.DeletePriceTier(db))
+
+			// Modifier groups
+			protected.GET("/modifier-groups", handlers.GetModifierGroups(db))
+			protected.POST("/modifier-groups", handlers.CreateModifierGroup(db))
+			protected.PUT("/modifier-groups/:id", handlers.UpdateModifierGroup(db))
+			protected.DELETE("/modifier-groups/:id", handlers.DeleteModifierGroup(db))
 
 			// Orders
 			protected.GET("/orders", handlers.GetOrders(db))
 			protected.GET("/orders/daily-sales", handlers.GetDailySales(db))
 			protected.GET("/orders/:id", handlers.GetOrder(db))
 			protected.POST("/orders", handlers.CreateOrder(db))
+			protected.POST("/orders/preview", handlers.PreviewOrder(db))
 			protected.PATCH("/orders/:id/status", handlers.UpdateOrderStatus(db))
+			protected.POST("/orders/:id/items", handlers.AddOrderItems(db))
+			protected.POST("/orders/:id/items/:line/void", handlers.VoidOrderItem(db))
+			protected.POST("/orders/:id/settle", handlers.SettleOrder(db))
+			protected.POST("/orders/:id/cancel", handlers.CancelOrder(db))
+			protected.POST("/orders/:id/split", handlers.SplitOrder(db))
+			protected.POST("/orders/:id/merge", handlers.MergeOrders(db))
+			protected.PATCH("/orders/:id/table", handlers.MoveOrder(db))
 
 			// Users
 			protected.GET("/users", handlers.GetUsers(db))
@@ -89,10 +129,81 @@ func main() {
 
 			// Customers
 			protected.GET("/customers", handlers.GetCustomers(db))
+			protected.GET("/customers/duplicates", handlers.GetCustomerDuplicates(db))
 			protected.GET("/customers/:id", handlers.GetCustomer(db))
 			protected.POST("/customers", handlers.CreateCustomer(db))
 			protected.PUT("/customers/:id", handlers.UpdateCustomer(db))
 			protected.DELETE("/customers/:id", handlers.DeleteCustomer(db))
+			protected.GET("/customers/:id/summary", handlers.GetCustomerSummary(db))
+			protected.POST("/customers/:id/merge", handlers.MergeCustomers(db))
+			protected.GET("/customers/:id/points", handlers.GetCustomerPoints(db))
+			protected.POST("/customers/:id/points", handlers.AdjustCustomerPoints(db))
+			protected.GET("/customers/:id/statement", handlers.GetCustomerStatement(db))
+			protected.POST("/customers/:id/payments", handlers.ReceiveCustomerPayment(db))
+
+			// Gift cards
+			protected.GET("/gift-cards", handlers.GetGiftCards(db))
+			protected.GET("/gift-cards/:id", handlers.GetGiftCard(db))
+			protected.POST("/gift-cards", handlers.IssueGiftCard(db))
+			protected.POST("/gift-cards/:id/top-up", handlers.TopUpGiftCard(db))
+			protected.POST("/gift-cards/:id/redeem", handlers.RedeemGiftCard(db))
+			protected.POST("/gift-cards/:id/void", handlers.VoidGiftCard(db))
+
+			// Floor plan
+			protected.GET("/floor-plan", handlers.GetFloorPlan(db))
+			protected.GET("/areas", handlers.GetAreas(db))
+			protected.POST("/areas", handlers.CreateArea(db))
+			protected.PUT("/areas/:id", handlers.UpdateArea(db))
+			protected.DELETE("/areas/:id", handlers.DeleteArea(db))
+			protected.GET("/tables", handlers.GetTables(db))
+			protected.POST("/tables", handlers.CreateTable(db))
+			protected.PUT("/tables/:id", handlers.UpdateTable(db))
+			protected.DELETE("/tables/:id", handlers.DeleteTable(db))
+			protected.PATCH("/tables/:id/status", handlers.UpdateTableStatus(db))
+
+			// Reservations
+			protected.GET("/reservations", handlers.GetReservations(db))
+			protected.GET("/reservations/:id", handlers.GetReservation(db))
+			protected.POST("/reservations", handlers.CreateReservation(db))
+			protected.PUT("/reservations/:id", handlers.UpdateReservation(db))
+			protected.PATCH("/reservations/:id/status", handlers.UpdateReservationStatus(db))
+
+			// Work orders
+			protected.GET("/work-orders", handlers.GetWorkOrders(db))
+			protected.GET("/work-orders/board", handlers.GetWorkOrderBoard(db))
+			protected.GET("/work-orders/:id", handlers.GetWorkOrder(db))
+			protected.POST("/work-orders", handlers.CreateWorkOrder(db))
+			protected.PUT("/work-orders/:id", handlers.UpdateWorkOrder(db))
+			protected.PATCH("/work-orders/:id/stage", handlers.UpdateWorkOrderStage(db))
+			protected.POST("/work-orders/:id/cancel", handlers.CancelWorkOrder(db))
+			protected.POST("/work-orders/:id/pickup", handlers.PickupWorkOrder(db))
+
+			// Appointments
+			protected.GET("/services", handlers.GetServices(db))
+			protected.POST("/services", handlers.CreateService(db))
+			protected.PUT("/services/:id", handlers.UpdateService(db))
+			protected.DELETE("/services/:id", handlers.DeleteService(db))
+			protected.GET("/staff", handlers.GetStaff(db))
+			protected.GET("/staff/:id", handlers.GetStaffMember(db))
+			protected.POST("/staff", handlers.CreateStaff(db))
+			protected.PUT("/staff/:id", handlers.UpdateStaff(db))
+			protected.DELETE("/staff/:id", handlers.DeleteStaff(db))
+			protected.GET("/appointments", handlers.GetAppointments(db))
+			protected.GET("/appointments/availability", handlers.GetAppointmentAvailability(db))
+			protected.GET("/appointments/:id", handlers.GetAppointment(db))
+			protected.POST("/appointments", handlers.CreateAppointment(db))
+			protected.PUT("/appointments/:id", handlers.UpdateAppointment(db))
+			protected.PATCH("/appointments/:id/status", handlers.UpdateAppointmentStatus(db))
+			protected.POST("/appointments/:id/check-in", handlers.CheckInAppointment(db))
+
+			// Kitchen display
+			protected.GET("/kitchen/tickets", handlers.GetKitchenTickets(db))
+			protected.GET("/kitchen/stream", handlers.StreamKitchenTickets(db))
+			protected.PATCH("/kitchen/tickets/:id/status", handlers.UpdateKitchenTicketStatus(db))
+			protected.PATCH("/kitchen/items/:id/status", handlers.UpdateKitchenItemStatus(db))
+
+			// Receivables
+			protected.GET("/receivables/aging", handlers.GetReceivablesAging(db))
 
 			// Import
 			protected.POST("/products/import", handlers.ImportProducts(db))
@@ -102,6 +213,10 @@ func main() {
 			protected.GET("/stock/logs/:productId", handlers.GetProductStockHistory(db))
 			protected.POST("/stock/adjust", handlers.AdjustStock(db))
 			protected.POST("/stock/restock", handlers.RestockProduct(db))
+			protected.GET("/stock/lots", handlers.GetStockLots(db))
+			protected.GET("/stock/expiring", handlers.GetExpiringLots(db))
+			protected.POST("/stock/lots/:id/write-off", handlers.WriteOffLot(db))
+			protected.GET("/stock/usage", handlers.GetIngredientUsage(db))
 
 			// Suppliers
 			protected.GET("/suppliers", handlers.GetSuppliers(db))
@@ -109,6 +224,26 @@ func main() {
 			protected.POST("/suppliers", handlers.CreateSupplier(db))
 			protected.PUT("/suppliers/:id", handlers.UpdateSupplier(db))
 			protected.DELETE("/suppliers/:id", handlers.DeleteSupplier(db))
+
+			// Promotions
+			protected.GET("/promotions", handlers.GetPromotions(db))
+			protected.POST("/promotions", handlers.CreatePromotion(db))
+			protected.PUT("/promotions/:id", handlers.UpdatePromotion(db))
+			protected.DELETE("/promotions/:id", handlers.DeletePromotion(db))
+
+			// Tax rules
+			protected.GET("/tax-rules", handlers.GetTaxRules(db))
+			protected.POST("/tax-rules", handlers.CreateTaxRule(db))
+			protected.PUT("/tax-rules/:id", handlers.UpdateTaxRule(db))
+			protected.DELETE("/tax-rules/:id", handlers.DeleteTaxRule(db))
+
+			// Vouchers
+			protected.GET("/vouchers", handlers.GetVouchers(db))
+			protected.GET("/vouchers/report", handlers.GetVoucherReport(db))
+			protected.POST("/vouchers", handlers.CreateVoucher(db))
+			protected.PUT("/vouchers/:id", handlers.UpdateVoucher(db))
+			protected.DELETE("/vouchers/:id", handlers.DeleteVoucher(db))
+			protected.GET("/vouchers/:id/redemptions", handlers.GetVoucherRedemptions(db))
 		}
 
 		// Superadmin routes (require superadmin role)
@@ -0,0 +1,82 @@
+package barcode
+
+import (
+	"errors"
+	"strings"
+)
+
+// Barcode types stored on ProductBarcode
+const (
+	EAN13 = "EAN13"
+	UPCA  = "UPCA"
+	EAN8  = "EAN8"
+	Other = "OTHER"
+)
+
+// ErrInvalidCheckDigit is returned when a numeric GTIN fails its check digit
+var ErrInvalidCheckDigit = errors.New("invalid barcode check digit")
+
+func isDigits(code string) bool {
+	if code == "" {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckDigit computes the GS1 check digit for the digits of a GTIN without its last digit
+func CheckDigit(body string) int {
+	sum := 0
+	// Weights alternate 3,1,3... starting from the rightmost digit
+	for i := len(body) - 1; i >= 0; i-- {
+		d := int(body[i] - '0')
+		if (len(body)-1-i)%2 == 0 {
+			sum += d * 3
+		} else {
+			sum += d
+		}
+	}
+	return (10 - sum%10) % 10
+}
+
+// ValidCheckDigit reports whether a numeric GTIN (EAN-8, UPC-A, EAN-13) has a valid check digit
+func ValidCheckDigit(code string) bool {
+	if !isDigits(code) || len(code) < 2 {
+		return false
+	}
+	return CheckDigit(code[:len(code)-1]) == int(code[len(code)-1]-'0')
+}
+
+// Classify normalizes a scanned code and determines its type.
+// EAN-13, UPC-A and EAN-8 codes must carry a valid check digit; anything
+// else (internal or Code 128 labels) is accepted as OTHER.
+func Classify(code string) (string, string, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return "", "", errors.New("barcode is empty")
+	}
+
+	if isDigits(code) {
+		var kind string
+		switch len(code) {
+		case 13:
+			kind = EAN13
+		case 12:
+			kind = UPCA
+		case 8:
+			kind = EAN8
+		}
+		if kind != "" {
+			if !ValidCheckDigit(code) {
+				return code, "", ErrInvalidCheckDigit
+			}
+			return code, kind, nil
+		}
+	}
+
+	return code, Other, nil
+}
@@ -0,0 +1,80 @@
+package barcode
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Values embedded in scale barcodes
+const (
+	Weight = "weight"
+	Price  = "price"
+)
+
+// ScaleLayout describes an in-store EAN-13 printed by a scale or label printer:
+// a 2-digit prefix, the item code (PLU), the embedded value and the EAN check digit.
+type ScaleLayout struct {
+	Prefixes       []string `json:"prefixes"`         // e.g. ["20","21"]
+	ItemCodeLength int      `json:"item_code_length"` // Digits after the prefix identifying the item
+	ValueType      string   `json:"value_type"`       // weight or price
+	ValueDecimals  int      `json:"value_decimals"`   // Implied decimals, e.g. 3 for grams to kg
+}
+
+// DefaultScaleLayouts reads prefixes 20-29 as a 5-digit item code followed by
+// a 5-digit weight in grams
+var DefaultScaleLayouts = []ScaleLayout{{
+	Prefixes:       []string{"20", "21", "22", "23", "24", "25", "26", "27", "28", "29"},
+	ItemCodeLength: 5,
+	ValueType:      Weight,
+	ValueDecimals:  3,
+}}
+
+// ScaleValue is the decoded content of a scale barcode
+type ScaleValue struct {
+	ItemCode  string  `json:"item_code"`
+	ValueType string  `json:"value_type"`
+	Value     float64 `json:"value"` // Weight in the product's unit, or the printed price
+}
+
+// ParseScale decodes a scale barcode using the first layout whose prefix matches.
+// It returns false when the code is not a valid EAN-13 or no layout applies.
+func ParseScale(code string, layouts []ScaleLayout) (ScaleValue, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != 13 || !ValidCheckDigit(code) {
+		return ScaleValue{}, false
+	}
+
+	for _, layout := range layouts {
+		if !hasPrefix(code, layout.Prefixes) {
+			continue
+		}
+
+		// prefix (2) + item code + value + check digit (1)
+		start := 2 + layout.ItemCodeLength
+		if layout.ItemCodeLength <= 0 || start >= 12 {
+			continue
+		}
+		raw, err := strconv.Atoi(code[start:12])
+		if err != nil {
+			continue
+		}
+
+		return ScaleValue{
+			ItemCode:  code[2:start],
+			ValueType: layout.ValueType,
+			Value:     float64(raw) / math.Pow10(layout.ValueDecimals),
+		}, true
+	}
+
+	return ScaleValue{}, false
+}
+
+func hasPrefix(code string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if p != "" && strings.HasPrefix(code, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,19 @@
 package database
 
 import (
+	"bytes"
+	"encoding/json"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
 	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"ringpos-backend/internal/phone"
 )
 
 func getEnv(key, defaultValue string) string {
@@ -40,13 +46,279 @@ func Connect() (*gorm.DB, error) {
 
 func Migrate(db *gorm.DB) error {
 	log.Println("🔄 Running database migrations...")
-	return db.AutoMigrate(
+	hadTaxRules := db.Migrator().HasTable(&models.TaxRule{})
+	if err := db.AutoMigrate(
 		&models.Tenant{},
 		&models.User{},
 		&models.Product{},
 		&models.Order{},
 		&models.Customer{},
 		&models.StockLog{},
+		&models.StockLot{},
 		&models.Supplier{},
-	)
+		&models.ProductBarcode{},
+		&models.ProductUnit{},
+		&models.PriceTier{},
+		&models.RecipeLine{},
+		&models.ProductAvailability{},
+		&models.Promotion{},
+		&models.Voucher{},
+		&models.VoucherRedemption{},
+		&models.TaxRule{},
+		&models.LoyaltyEntry{},
+		&models.ReceivableEntry{},
+		&models.GiftCard{},
+		&models.GiftCardEntry{},
+		&models.Area{},
+		&models.Table{},
+		&models.Reservation{},
+		&models.ModifierGroup{},
+		&models.Modifier{},
+		&models.ProductModifierGroup{},
+		&models.KitchenTicket{},
+		&models.KitchenTicketItem{},
+		&models.WorkOrder{},
+		&models.WorkOrderPhoto{},
+		&models.ServiceDefinition{},
+		&models.Staff{},
+		&models.StaffHours{},
+		&models.Appointment{},
+		&schemaMigration{},
+	); err != nil {
+		return err
+	}
+
+	if err := runOnce(db, "money_minor_units", migrateMoneyMinorUnits); err != nil {
+		return err
+	}
+	if err := migrateWholesaleRules(db); err != nil {
+		return err
+	}
+	if err := migrateOrderCustomers(db); err != nil {
+		return err
+	}
+	if err := runOnce(db, "customer_phones_e164", migrateCustomerPhones); err != nil {
+		return err
+	}
+	if !hadTaxRules {
+		return migrateDefaultTaxRules(db)
+	}
+	return nil
+}
+
+// schemaMigration records a data migration that has run, for the ones that
+// cannot tell from the data whether they are still needed
+type schemaMigration struct {
+	ID        string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+// runOnce runs a data migration in a transaction unless it has run before
+func runOnce(db *gorm.DB, id string, migrate func(tx *gorm.DB) error) error {
+	var count int64
+	db.Model(&schemaMigration{}).Where("id = ?", id).Count(&count)
+	if count > 0 {
+		return nil
+	}
+
+	log.Printf("🔄 Running data migration %s...", id)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := migrate(tx); err != nil {
+			return err
+		}
+		return tx.Create(&schemaMigration{ID: id, AppliedAt: time.Now()}).Error
+	})
+}
+
+// moneyColumns are the columns holding money.Amount, by table
+var moneyColumns = map[string][]string{
+	"products":            {"price"},
+	"product_units":       {"price"},
+	"price_tiers":         {"price"},
+	"promotions":          {"min_spend"},
+	"vouchers":            {"max_discount", "min_spend"},
+	"voucher_redemptions": {"amount"},
+	"orders":              {"total", "tax"},
+}
+
+// migrateMoneyMinorUnits converts amounts stored as floats in the major unit to
+// integer minor units, and moves the fixed amounts of promotions and vouchers
+// out of value, which now only holds percentages. On a new database the tables
+// are empty and only the migration is recorded.
+func migrateMoneyMinorUnits(tx *gorm.DB) error {
+	for table, columns := range moneyColumns {
+		for _, column := range columns {
+			if err := tx.Exec("UPDATE " + table + " SET " + column + " = CAST(ROUND(" + column + " * 100) AS INTEGER)").Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Exec("UPDATE promotions SET amount = CAST(ROUND(value * 100) AS INTEGER), value = 0 WHERE type IN ('fixed', 'bundle')").Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("UPDATE vouchers SET amount = CAST(ROUND(value * 100) AS INTEGER), value = 0 WHERE type = 'fixed'").Error; err != nil {
+		return err
+	}
+	return migrateOrderDetailsMoney(tx)
+}
+
+// detailsMoneyKeys are the keys that hold amounts in an order's details, at any
+// depth: the order's totals, its lines and voided lines, discounts, taxes and payments
+var detailsMoneyKeys = map[string]bool{
+	"price": true, "subtotal": true, "discount": true, "tax": true, "tax_included": true,
+	"total": true, "amount": true, "base": true, "change": true, "amount_due": true, "rounding": true,
+}
+
+// migrateOrderDetailsMoney converts the amounts kept in the orders' details JSON
+// to minor units along with the columns, so receipts, tabs and split bills read
+// the same amounts as before
+func migrateOrderDetailsMoney(tx *gorm.DB) error {
+	var orders []models.Order
+	if err := tx.Select("id", "details").Where("details <> ''").Find(&orders).Error; err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		// Numbers are decoded as written so the ones that are not amounts keep their form
+		decoder := json.NewDecoder(bytes.NewReader([]byte(order.Details)))
+		decoder.UseNumber()
+		var details interface{}
+		if err := decoder.Decode(&details); err != nil {
+			log.Printf("Skipping unreadable details on order %d: %v", order.ID, err)
+			continue
+		}
+
+		converted, err := json.Marshal(scaleDetailsMoney(details))
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).UpdateColumn("details", string(converted)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scaleDetailsMoney multiplies the amounts in decoded order details by 100
+func scaleDetailsMoney(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if number, ok := field.(json.Number); ok && detailsMoneyKeys[key] {
+				if amount, err := number.Float64(); err == nil {
+					v[key] = int64(math.Round(amount * 100))
+				}
+				continue
+			}
+			v[key] = scaleDetailsMoney(field)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = scaleDetailsMoney(v[i])
+		}
+	}
+	return value
+}
+
+// migrateWholesaleRules turns the wholesale_rules the client used to keep in
+// Product.Metadata into price tiers for everyone, and removes them from the metadata
+func migrateWholesaleRules(db *gorm.DB) error {
+	var products []models.Product
+	if err := db.Where("metadata LIKE ?", "%wholesale_rules%").Find(&products).Error; err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		var metadata map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(product.Metadata), &metadata); err != nil {
+			log.Printf("Skipping unreadable metadata on product %d: %v", product.ID, err)
+			continue
+		}
+
+		var rules []struct {
+			MinQty float64      `json:"min_qty"`
+			Price  money.Amount `json:"price"`
+		}
+		if err := json.Unmarshal(metadata["wholesale_rules"], &rules); err != nil {
+			log.Printf("Skipping unreadable wholesale_rules on product %d: %v", product.ID, err)
+			continue
+		}
+
+		tx := db.Begin()
+		for _, rule := range rules {
+			if rule.MinQty <= 0 || rule.Price <= 0 {
+				continue
+			}
+			tier := models.PriceTier{TenantID: product.TenantID, ProductID: product.ID, MinQty: rule.MinQty, Price: rule.Price}
+			if err := tx.Create(&tier).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		delete(metadata, "wholesale_rules")
+		remaining := ""
+		if len(metadata) > 0 {
+			b, _ := json.Marshal(metadata)
+			remaining = string(b)
+		}
+		if err := tx.Model(&product).Update("metadata", remaining).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		tx.Commit()
+	}
+
+	return nil
+}
+
+// migrateOrderCustomers links orders to the customer recorded in their details,
+// for orders placed before Order.CustomerID existed
+func migrateOrderCustomers(db *gorm.DB) error {
+	return db.Exec(`UPDATE orders SET customer_id = json_extract(details, '$.customer_id')
+		WHERE customer_id IS NULL AND json_valid(details)
+		AND EXISTS (SELECT 1 FROM customers WHERE customers.id = json_extract(orders.details, '$.customer_id')
+			AND customers.tenant_id = orders.tenant_id)`).Error
+}
+
+// migrateCustomerPhones rewrites customer phones in E.164. Numbers that cannot
+// be read are left as they are for the tenant to fix.
+func migrateCustomerPhones(tx *gorm.DB) error {
+	var customers []models.Customer
+	if err := tx.Where("phone <> ''").Find(&customers).Error; err != nil {
+		return err
+	}
+
+	for _, customer := range customers {
+		normalized, err := phone.Normalize(customer.Phone, phone.DefaultCountryCode)
+		if err != nil {
+			log.Printf("Keeping unreadable phone %q on customer %d", customer.Phone, customer.ID)
+			continue
+		}
+		if normalized == customer.Phone {
+			continue
+		}
+		if err := tx.Model(&customer).UpdateColumn("phone", normalized).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateDefaultTaxRules gives tenants that predate tax rules the flat 10% the
+// POS app used to add itself, so their totals do not change
+func migrateDefaultTaxRules(db *gorm.DB) error {
+	var tenants []models.Tenant
+	if err := db.Find(&tenants).Error; err != nil {
+		return err
+	}
+
+	for _, tenant := range tenants {
+		rule := models.TaxRule{TenantID: tenant.ID, Name: "Tax", Rate: 10, Sequence: 1, Active: true}
+		if err := db.Create(&rule).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
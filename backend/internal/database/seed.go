@@ -2,6 +2,7 @@ package database
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"ringpos-backend/internal/models"
 
@@ -48,6 +49,7 @@ func Seed(db *gorm.DB) {
 		Status:           "active",
 		SubscriptionPlan: "pro",
 		ModulesEnabled:   `["barcode_scanner","wholesale_pricing","inventory"]`,
+		Config:           toJSON(map[string]string{"currency": "USD"}),
 	}
 	db.Create(&retailTenant)
 
@@ -68,6 +70,18 @@ func Seed(db *gorm.DB) {
 		Status:           "active",
 		SubscriptionPlan: "basic",
 		ModulesEnabled:   `["table_map","kitchen_print","modifiers"]`,
+		Config: toJSON(map[string]interface{}{
+			// 1 point per Rp 10.000, worth Rp 100; Gold members earn 1.5x
+			"loyalty": map[string]interface{}{
+				"earn_spend":  10000,
+				"point_value": 100,
+				"expiry_days": 365,
+				"tiers": []map[string]interface{}{
+					{"name": "Silver", "min_spend": 0, "multiplier": 1},
+					{"name": "Gold", "min_spend": 1000000, "multiplier": 1.5},
+				},
+			},
+		}),
 	}
 	db.Create(&fnbTenant)
 
@@ -79,38 +93,141 @@ func Seed(db *gorm.DB) {
 	}
 	db.Create(&fnbAdmin)
 
-	// Create sample products for retail tenant
+	// Create Service tenant
+	serviceTenant := models.Tenant{
+		Name:             "CleanWait Laundry Demo",
+		BusinessType:     models.Service,
+		Address:          "789 Market Lane",
+		Status:           "active",
+		SubscriptionPlan: "basic",
+		ModulesEnabled:   `["kanban_board","sms_notification","calendar"]`,
+	}
+	db.Create(&serviceTenant)
+
+	serviceAdmin := models.User{
+		Username: "laundryadmin",
+		Password: hashPassword("laundry123"),
+		TenantID: &serviceTenant.ID,
+		Role:     "owner",
+	}
+	db.Create(&serviceAdmin)
+
+	// Create sample products for retail tenant (prices in cents)
 	products := []models.Product{
-		{TenantID: retailTenant.ID, Name: "Fresh Whole Milk 1L", Price: 2.50, Stock: 50, Category: "Dairy", ImageURL: "milk"},
-		{TenantID: retailTenant.ID, Name: "Whole Wheat Bread", Price: 3.20, Stock: 30, Category: "Bakery", ImageURL: "bread"},
-		{TenantID: retailTenant.ID, Name: "Coca Cola 500ml", Price: 1.50, Stock: 100, Category: "Beverages", ImageURL: "cola"},
-		{TenantID: retailTenant.ID, Name: "Snickers Bar", Price: 0.99, Stock: 80, Category: "Snacks", ImageURL: "snickers"},
-		{TenantID: retailTenant.ID, Name: "Dove Soap Bar", Price: 1.20, Stock: 60, Category: "Household", ImageURL: "soap"},
-		{TenantID: retailTenant.ID, Name: "Red Apples (kg)", Price: 3.50, Stock: 40, Category: "Produce", ImageURL: "apples"},
-		{TenantID: retailTenant.ID, Name: "Lays Classic", Price: 1.80, Stock: 70, Category: "Snacks", ImageURL: "lays"},
-		{TenantID: retailTenant.ID, Name: "Head & Shoulders", Price: 5.50, Stock: 25, Category: "Household", ImageURL: "shampoo"},
-		{TenantID: retailTenant.ID, Name: "Tomato Soup Can", Price: 1.10, Stock: 45, Category: "Pantry", ImageURL: "soup"},
-		{TenantID: retailTenant.ID, Name: "Mineral Water 1L", Price: 0.80, Stock: 120, Category: "Beverages", ImageURL: "water"},
-		{TenantID: retailTenant.ID, Name: "Rice 25kg", Price: 45.00, Stock: 20, Category: "Quick Keys", ImageURL: "rice",
-			Metadata: toJSON(map[string]interface{}{"wholesale_rules": []map[string]interface{}{{"min_qty": 5, "price": 43.00}, {"min_qty": 10, "price": 40.00}}})},
-		{TenantID: retailTenant.ID, Name: "LPG Cylinder", Price: 22.50, Stock: 15, Category: "Quick Keys", ImageURL: "lpg"},
-		{TenantID: retailTenant.ID, Name: "Egg Tray (30)", Price: 8.99, Stock: 25, Category: "Quick Keys", ImageURL: "eggs"},
-	}
-
-	// Create sample products for F&B tenant
+		{TenantID: retailTenant.ID, Name: "Fresh Whole Milk 1L", Price: 250, Stock: 50, Category: "Dairy", ImageURL: "milk"},
+		{TenantID: retailTenant.ID, Name: "Whole Wheat Bread", Price: 320, Stock: 30, Category: "Bakery", ImageURL: "bread"},
+		{TenantID: retailTenant.ID, Name: "Coca Cola 500ml", Price: 150, Stock: 100, Category: "Beverages", ImageURL: "cola",
+			Units: []models.ProductUnit{{TenantID: retailTenant.ID, Name: "crate", Factor: 24}}},
+		{TenantID: retailTenant.ID, Name: "Snickers Bar", Price: 99, Stock: 80, Category: "Snacks", ImageURL: "snickers"},
+		{TenantID: retailTenant.ID, Name: "Dove Soap Bar", Price: 120, Stock: 60, Category: "Household", ImageURL: "soap"},
+		{TenantID: retailTenant.ID, Name: "Red Apples (kg)", Price: 350, Stock: 40, Unit: "kg", PLU: "00001", Category: "Produce", ImageURL: "apples"},
+		{TenantID: retailTenant.ID, Name: "Lays Classic", Price: 180, Stock: 70, Category: "Snacks", ImageURL: "lays"},
+		{TenantID: retailTenant.ID, Name: "Head & Shoulders", Price: 550, Stock: 25, Category: "Household", ImageURL: "shampoo"},
+		{TenantID: retailTenant.ID, Name: "Tomato Soup Can", Price: 110, Stock: 45, Category: "Pantry", ImageURL: "soup"},
+		{TenantID: retailTenant.ID, Name: "Mineral Water 1L", Price: 80, Stock: 120, Category: "Beverages", ImageURL: "water"},
+		{TenantID: retailTenant.ID, Name: "Rice 25kg", Price: 4500, Stock: 20, Category: "Quick Keys", ImageURL: "rice",
+			PriceTiers: []models.PriceTier{
+				{TenantID: retailTenant.ID, MinQty: 5, Price: 4300},
+				{TenantID: retailTenant.ID, MinQty: 10, Price: 4000},
+				{TenantID: retailTenant.ID, MinQty: 1, Price: 4200, CustomerGroup: "wholesale"},
+			}},
+		{TenantID: retailTenant.ID, Name: "LPG Cylinder", Price: 2250, Stock: 15, Category: "Quick Keys", ImageURL: "lpg"},
+		{TenantID: retailTenant.ID, Name: "Egg Tray (30)", Price: 899, Stock: 25, Category: "Quick Keys", ImageURL: "eggs"},
+	}
+
+	// Create sample products for F&B tenant (amounts keep two decimals, so Rp 18.000 is 1800000)
 	fnbProducts := []models.Product{
-		{TenantID: fnbTenant.ID, Name: "Americano", Price: 18000, Stock: 999, Category: "Coffee", ImageURL: "coffee"},
-		{TenantID: fnbTenant.ID, Name: "Latte", Price: 25000, Stock: 999, Category: "Coffee", ImageURL: "latte"},
-		{TenantID: fnbTenant.ID, Name: "Croissant", Price: 15000, Stock: 50, Category: "Pastry", ImageURL: "croissant"},
-		{TenantID: fnbTenant.ID, Name: "Cheesecake", Price: 35000, Stock: 20, Category: "Dessert", ImageURL: "cake"},
+		{TenantID: fnbTenant.ID, Name: "Americano", Price: 1800000, Stock: 999, Category: "Coffee", ImageURL: "coffee", KitchenStation: "bar"},
+		{TenantID: fnbTenant.ID, Name: "Latte", Price: 2500000, Stock: 999, Category: "Coffee", ImageURL: "latte", KitchenStation: "bar"},
+		{TenantID: fnbTenant.ID, Name: "Croissant", Price: 1500000, Stock: 50, Category: "Pastry", ImageURL: "croissant", KitchenStation: "pastry"},
+		{TenantID: fnbTenant.ID, Name: "Cheesecake", Price: 3500000, Stock: 20, Category: "Dessert", ImageURL: "cake", KitchenStation: "pastry"},
+	}
+
+	// Laundry services are taken in as work orders; Wash & Fold is charged by weight
+	serviceProducts := []models.Product{
+		{TenantID: serviceTenant.ID, Name: "Wash & Fold", Price: 800000, Stock: 999, Unit: "kg", Category: "Laundry"},
+		{TenantID: serviceTenant.ID, Name: "Wash & Iron", Price: 1200000, Stock: 999, Unit: "kg", Category: "Laundry"},
+		{TenantID: serviceTenant.ID, Name: "Dry Clean Suit", Price: 4500000, Stock: 999, Category: "Dry Cleaning"},
+		{TenantID: serviceTenant.ID, Name: "Laundry Bag", Price: 2500000, Stock: 30, Category: "Retail"},
 	}
 
 	for _, p := range products {
 		db.Create(&p)
 	}
-	for _, p := range fnbProducts {
+	for _, p := range serviceProducts {
 		db.Create(&p)
 	}
+	for i := range fnbProducts {
+		db.Create(&fnbProducts[i])
+	}
+
+	// Ingredients for F&B: the coffees are made to a recipe, so selling one uses
+	// up beans, milk and a cup rather than stock of the drink itself
+	ingredients := []models.Product{
+		{TenantID: fnbTenant.ID, Name: "Coffee Beans", Stock: 5000, Unit: "g", Category: "Ingredients"},
+		{TenantID: fnbTenant.ID, Name: "Fresh Milk", Stock: 20000, Unit: "ml", Category: "Ingredients"},
+		{TenantID: fnbTenant.ID, Name: "Cup 12oz", Stock: 500, Unit: "pcs", Category: "Ingredients"},
+	}
+	for i := range ingredients {
+		db.Create(&ingredients[i])
+	}
+	beans, milk, cup := ingredients[0], ingredients[1], ingredients[2]
+	recipes := []models.RecipeLine{
+		{TenantID: fnbTenant.ID, ProductID: fnbProducts[0].ID, IngredientID: beans.ID, Quantity: 18},
+		{TenantID: fnbTenant.ID, ProductID: fnbProducts[0].ID, IngredientID: cup.ID, Quantity: 1},
+		{TenantID: fnbTenant.ID, ProductID: fnbProducts[1].ID, IngredientID: beans.ID, Quantity: 18},
+		{TenantID: fnbTenant.ID, ProductID: fnbProducts[1].ID, IngredientID: milk.ID, Quantity: 200},
+		{TenantID: fnbTenant.ID, ProductID: fnbProducts[1].ID, IngredientID: cup.ID, Quantity: 1},
+	}
+	for _, r := range recipes {
+		db.Create(&r)
+	}
+
+	// Taxes: a flat tax for retail; a service charge with PB1 on top of it for F&B
+	taxRules := []models.TaxRule{
+		{TenantID: retailTenant.ID, Name: "Tax", Rate: 10, Sequence: 1, Active: true},
+		{TenantID: fnbTenant.ID, Name: "Service Charge", Rate: 5, Sequence: 1, Active: true},
+		{TenantID: fnbTenant.ID, Name: "PB1", Rate: 10, Sequence: 2, Compound: true, Active: true},
+	}
+	for _, r := range taxRules {
+		db.Create(&r)
+	}
+
+	// Coffee modifiers for F&B: a size is required, milk and extra shots are optional
+	size := models.ModifierGroup{TenantID: fnbTenant.ID, Name: "Size", MinSelect: 1, MaxSelect: 1, Modifiers: []models.Modifier{
+		{TenantID: fnbTenant.ID, Name: "Regular", Sequence: 1},
+		{TenantID: fnbTenant.ID, Name: "Large", PriceAdjustment: 500000, Sequence: 2},
+	}}
+	milkChoice := models.ModifierGroup{TenantID: fnbTenant.ID, Name: "Milk", MaxSelect: 1, Modifiers: []models.Modifier{
+		{TenantID: fnbTenant.ID, Name: "Oat milk", PriceAdjustment: 600000, Sequence: 1},
+		{TenantID: fnbTenant.ID, Name: "Almond milk", PriceAdjustment: 600000, Sequence: 2},
+	}}
+	extras := models.ModifierGroup{TenantID: fnbTenant.ID, Name: "Extras", Modifiers: []models.Modifier{
+		{TenantID: fnbTenant.ID, Name: "Extra shot", PriceAdjustment: 700000, Sequence: 1},
+		{TenantID: fnbTenant.ID, Name: "Less sugar", Sequence: 2},
+	}}
+	db.Create(&size)
+	db.Create(&milkChoice)
+	db.Create(&extras)
+	for _, p := range fnbProducts[:2] {
+		db.Create(&models.ProductModifierGroup{ProductID: p.ID, ModifierGroupID: size.ID, Sequence: 1})
+		db.Create(&models.ProductModifierGroup{ProductID: p.ID, ModifierGroupID: extras.ID, Sequence: 3})
+	}
+	db.Create(&models.ProductModifierGroup{ProductID: fnbProducts[1].ID, ModifierGroupID: milkChoice.ID, Sequence: 2})
+
+	// Floor plan for F&B: the dining room and the bar
+	dining := models.Area{TenantID: fnbTenant.ID, Name: "Dining Room", Sequence: 1}
+	bar := models.Area{TenantID: fnbTenant.ID, Name: "Bar", Sequence: 2}
+	db.Create(&dining)
+	db.Create(&bar)
+	for i, seats := range []int{2, 2, 4, 4, 4, 6, 6, 8} {
+		db.Create(&models.Table{TenantID: fnbTenant.ID, AreaID: dining.ID, Name: fmt.Sprintf("Table %d", i+1), Seats: seats,
+			Shape: "square", X: float64(i%4) * 120, Y: float64(i/4) * 120, Width: 80, Height: 80, Status: "available"})
+	}
+	for i := 0; i < 4; i++ {
+		db.Create(&models.Table{TenantID: fnbTenant.ID, AreaID: bar.ID, Name: fmt.Sprintf("Bar %d", i+1), Seats: 1,
+			Shape: "round", X: float64(i) * 60, Width: 40, Height: 40, Status: "available"})
+	}
 
 	log.Println("✅ Database seeded with demo data!")
 	log.Printf("   - Superadmin: superadmin (password: super123)")
@@ -118,7 +235,9 @@ func Seed(db *gorm.DB) {
 	log.Printf("     Admin: admin (password: admin123)")
 	log.Printf("   - F&B Tenant: %s", fnbTenant.Name)
 	log.Printf("     Admin: fnbadmin (password: fnb123)")
-	log.Printf("   - %d Products total", len(products)+len(fnbProducts))
+	log.Printf("   - Service Tenant: %s", serviceTenant.Name)
+	log.Printf("     Admin: laundryadmin (password: laundry123)")
+	log.Printf("   - %d Products total", len(products)+len(fnbProducts)+len(ingredients)+len(serviceProducts))
 }
 
 func toJSON(v interface{}) string {
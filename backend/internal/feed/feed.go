@@ -0,0 +1,54 @@
+// Package feed fans events out to the live screens of a tenant, such as
+// kitchen displays following tickets over Server-Sent Events.
+package feed
+
+import "sync"
+
+// Event is something that happened, named by Type for the screen to handle
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+// Hub keeps the subscribers of each tenant
+type Hub struct {
+	mu   sync.Mutex
+	subs map[uint]map[chan Event]struct{}
+}
+
+// NewHub returns a hub without subscribers
+func NewHub() *Hub {
+	return &Hub{subs: map[uint]map[chan Event]struct{}{}}
+}
+
+// Subscribe returns the tenant's events and a function that stops them
+func (h *Hub) Subscribe(tenantID uint) (<-chan Event, func()) {
+	ch := make(chan Event, 32)
+
+	h.mu.Lock()
+	if h.subs[tenantID] == nil {
+		h.subs[tenantID] = map[chan Event]struct{}{}
+	}
+	h.subs[tenantID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs[tenantID], ch)
+		h.mu.Unlock()
+	}
+}
+
+// Publish sends an event to the tenant's subscribers. A subscriber that is not
+// keeping up misses the event rather than holding up the sender, and reloads
+// when it reconnects.
+func (h *Hub) Publish(tenantID uint, event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[tenantID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
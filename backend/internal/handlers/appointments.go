@@ -0,0 +1,514 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/phone"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// activeAppointmentStatuses are the appointments that hold a staff member's time
+var activeAppointmentStatuses = []string{"booked", "checked_in"}
+
+// appointmentConfig is the booking calendar in the tenant settings
+type appointmentConfig struct {
+	SlotMinutes int `json:"slot_minutes"` // Open slots start this many minutes apart; defaults to 15
+}
+
+// applyDefaults fills in the calendar left unset
+func (a *appointmentConfig) applyDefaults() {
+	if a.SlotMinutes == 0 {
+		a.SlotMinutes = 15
+	}
+}
+
+// validate checks the slot length is sensible
+func (a appointmentConfig) validate() error {
+	if a.SlotMinutes < 0 || a.SlotMinutes > 24*60 {
+		return errors.New("slot_minutes must be between 1 and 1440")
+	}
+	return nil
+}
+
+// staffBookings lists when a staff member is booked within r, other than appointment excludeID
+func staffBookings(db *gorm.DB, staffID uint, r timeRange, excludeID uint) ([]timeRange, error) {
+	var appointments []models.Appointment
+	if err := db.Where("staff_id = ? AND id <> ? AND status IN ?", staffID, excludeID, activeAppointmentStatuses).
+		Where("start_at < ? AND end_at > ?", r.End.UTC(), r.Start.UTC()).
+		Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+	booked := make([]timeRange, len(appointments))
+	for i, a := range appointments {
+		booked[i] = timeRange{a.StartAt, a.EndAt}
+	}
+	return booked, nil
+}
+
+// openSlots lists the times on a day a staff member can start a service of
+// the given length, no earlier than now. day is midnight in the tenant's timezone.
+func openSlots(db *gorm.DB, staff models.Staff, duration time.Duration, day time.Time, step time.Duration, now time.Time) ([]timeRange, error) {
+	booked, err := staffBookings(db, staff.ID, timeRange{day, day.AddDate(0, 0, 1)}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := []timeRange{}
+	for _, work := range workingRanges(staff, day) {
+		for start := work.Start; !start.Add(duration).After(work.End); start = start.Add(step) {
+			slot := timeRange{start, start.Add(duration)}
+			if start.Before(now) {
+				continue
+			}
+			free := true
+			for _, b := range booked {
+				if slot.overlaps(b) {
+					free = false
+					break
+				}
+			}
+			if free {
+				slots = append(slots, slot)
+			}
+		}
+	}
+	return slots, nil
+}
+
+// checkStaffFree makes sure a staff member works through an appointment and is
+// not booked for another. The status code says whether the request is wrong
+// or the time is taken.
+func checkStaffFree(db *gorm.DB, staff models.Staff, a models.Appointment, loc *time.Location) (int, error) {
+	if !staff.Active {
+		return http.StatusBadRequest, errors.New(staff.Name + " is not taking appointments")
+	}
+	if !performs(staff, a.ServiceID) {
+		return http.StatusBadRequest, errors.New(staff.Name + " does not perform this service")
+	}
+
+	r := timeRange{a.StartAt, a.EndAt}
+	start := a.StartAt.In(loc)
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	working := false
+	for _, w := range workingRanges(staff, day) {
+		if !r.Start.Before(w.Start) && !r.End.After(w.End) {
+			working = true
+		}
+	}
+	if !working {
+		return http.StatusConflict, errors.New(staff.Name + " is not working at that time")
+	}
+
+	booked, err := staffBookings(db, staff.ID, r, a.ID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if len(booked) > 0 {
+		return http.StatusConflict, errors.New(staff.Name + " is already booked at that time")
+	}
+	return http.StatusOK, nil
+}
+
+// loadTenantAppointment fetches the appointment in the URL with its service and checks tenant access
+func loadTenantAppointment(c *gin.Context, db *gorm.DB) (models.Appointment, bool) {
+	var appointment models.Appointment
+	if err := db.Preload("Service.Product").First(&appointment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+		return appointment, false
+	}
+
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil || appointment.TenantID != *tenantID.(*uint) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return appointment, false
+		}
+	}
+
+	return appointment, true
+}
+
+// AppointmentRequest - Request body for booking or rescheduling an appointment
+type AppointmentRequest struct {
+	ServiceID     uint      `json:"service_id" binding:"required"`
+	StaffID       *uint     `json:"staff_id"`                    // Leave out to book whoever is free
+	StartAt       time.Time `json:"start_at" binding:"required"` // RFC3339
+	CustomerID    *uint     `json:"customer_id"`
+	CustomerName  string    `json:"customer_name"` // Taken from the customer when customer_id is set
+	CustomerPhone string    `json:"customer_phone"`
+	Note          string    `json:"note"`
+}
+
+// apply validates the request, assigns a staff member and copies it onto an
+// appointment. The status code says whether the request is wrong or the time is taken.
+func (req AppointmentRequest) apply(db *gorm.DB, cfg tenantConfig, a *models.Appointment) (int, error) {
+	a.CustomerID = req.CustomerID
+	a.CustomerName = req.CustomerName
+	a.CustomerPhone = req.CustomerPhone
+	if req.CustomerID != nil {
+		var customer models.Customer
+		if err := db.Where("id = ? AND tenant_id = ?", *req.CustomerID, a.TenantID).First(&customer).Error; err != nil {
+			return http.StatusBadRequest, errors.New("Customer not found")
+		}
+		if a.CustomerName == "" {
+			a.CustomerName = customer.Name
+		}
+		if a.CustomerPhone == "" {
+			a.CustomerPhone = customer.Phone
+		}
+	}
+	if a.CustomerName == "" {
+		return http.StatusBadRequest, errors.New("customer_name is required")
+	}
+	if a.CustomerPhone != "" {
+		normalized, err := phone.Normalize(a.CustomerPhone, cfg.PhoneCountryCode)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		a.CustomerPhone = normalized
+	}
+
+	var service models.ServiceDefinition
+	if err := db.Where("id = ? AND tenant_id = ?", req.ServiceID, a.TenantID).Preload("Product").First(&service).Error; err != nil {
+		return http.StatusBadRequest, errors.New("Service not found")
+	}
+	if !service.Active {
+		return http.StatusBadRequest, errors.New(service.Product.Name + " is not taking appointments")
+	}
+
+	// A booking that is under way keeps its start time when other details change
+	startAt := req.StartAt.UTC() // Stored in UTC so times compare in the database
+	if !startAt.Equal(a.StartAt) && startAt.Before(time.Now()) {
+		return http.StatusBadRequest, errors.New("Appointments cannot start in the past")
+	}
+
+	a.ServiceID = service.ID
+	a.Service = service
+	a.StartAt = startAt
+	a.EndAt = a.StartAt.Add(time.Duration(service.Duration) * time.Minute)
+	a.Note = req.Note
+
+	// Without a staff member, the first one free gets the booking
+	query := db.Where("tenant_id = ?", a.TenantID).Preload("Hours").Preload("Services").Order("id ASC")
+	if req.StaffID != nil {
+		query = query.Where("id = ?", *req.StaffID)
+	} else {
+		query = query.Where("active = ?", true)
+	}
+	var candidates []models.Staff
+	if err := query.Find(&candidates).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if req.StaffID != nil && len(candidates) == 0 {
+		return http.StatusBadRequest, errors.New("Staff member not found")
+	}
+
+	loc := cfg.location()
+	for _, staff := range candidates {
+		status, err := checkStaffFree(db, staff, *a, loc)
+		if err == nil {
+			a.StaffID = staff.ID
+			a.StaffName = staff.Name
+			return http.StatusOK, nil
+		}
+		if req.StaffID != nil {
+			return status, err
+		}
+	}
+	return http.StatusConflict, errors.New("No staff member is free at that time")
+}
+
+// StaffSlots - When one staff member can start a service
+type StaffSlots struct {
+	StaffID uint        `json:"staff_id"`
+	Name    string      `json:"name"`
+	Slots   []timeRange `json:"slots"`
+}
+
+// GetAppointmentAvailability - GET /api/appointments/availability?service_id=&date=&staff_id=
+// Lists the open slots for a service on a day, today by default, for each staff
+// member who performs it. Dates are in the tenant's timezone.
+func GetAppointmentAvailability(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		var service models.ServiceDefinition
+		if err := db.Where("id = ? AND tenant_id = ?", c.Query("service_id"), *tenantID.(*uint)).First(&service).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+
+		cfg := loadTenantConfig(db, *tenantID.(*uint))
+		loc := cfg.location()
+		now := time.Now()
+		day, err := time.ParseInLocation("2006-01-02", c.DefaultQuery("date", now.In(loc).Format("2006-01-02")), loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date, use YYYY-MM-DD"})
+			return
+		}
+
+		query := db.Where("tenant_id = ? AND active = ?", *tenantID.(*uint), true).Preload("Hours").Preload("Services").Order("name ASC")
+		if staffID := c.Query("staff_id"); staffID != "" {
+			query = query.Where("id = ?", staffID)
+		}
+		var staff []models.Staff
+		if err := query.Find(&staff).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		step := time.Duration(cfg.Appointments.SlotMinutes) * time.Minute
+		duration := time.Duration(service.Duration) * time.Minute
+		availability := []StaffSlots{}
+		for _, s := range staff {
+			if !performs(s, service.ID) {
+				continue
+			}
+			slots, err := openSlots(db, s, duration, day, step, now)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			availability = append(availability, StaffSlots{StaffID: s.ID, Name: s.Name, Slots: slots})
+		}
+
+		c.JSON(http.StatusOK, availability)
+	}
+}
+
+// GetAppointments - GET /api/appointments?date=&staff_id=&status=
+// The calendar of a day, today by default, in time order. Dates are in the tenant's timezone.
+func GetAppointments(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		loc := loadTenantConfig(db, *tenantID.(*uint)).location()
+		day, err := time.ParseInLocation("2006-01-02", c.DefaultQuery("date", time.Now().In(loc).Format("2006-01-02")), loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date, use YYYY-MM-DD"})
+			return
+		}
+
+		query := db.Where("tenant_id = ? AND start_at >= ? AND start_at < ?", *tenantID.(*uint), day.UTC(), day.AddDate(0, 0, 1).UTC()).
+			Preload("Service.Product").Order("start_at ASC, id ASC")
+		if staffID := c.Query("staff_id"); staffID != "" {
+			query = query.Where("staff_id = ?", staffID)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+
+		var appointments []models.Appointment
+		if err := query.Find(&appointments).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, appointments)
+	}
+}
+
+// GetAppointment - GET /api/appointments/:id
+func GetAppointment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appointment, ok := loadTenantAppointment(c, db)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, appointment)
+	}
+}
+
+// CreateAppointment - POST /api/appointments
+func CreateAppointment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req AppointmentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		appointment := models.Appointment{TenantID: *tenantID.(*uint), Status: "booked"}
+		status := http.StatusInternalServerError
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			code, err := req.apply(tx, loadTenantConfig(tx, appointment.TenantID), &appointment)
+			if err != nil {
+				status = code
+				return err
+			}
+			return tx.Omit("Service").Create(&appointment).Error
+		}); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, appointment)
+	}
+}
+
+// UpdateAppointment - PUT /api/appointments/:id
+// Reschedules a booking; checked-in appointments can no longer be changed.
+func UpdateAppointment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appointment, ok := loadTenantAppointment(c, db)
+		if !ok {
+			return
+		}
+		if appointment.Status != "booked" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Appointment is " + appointment.Status})
+			return
+		}
+
+		var req AppointmentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		status := http.StatusInternalServerError
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			code, err := req.apply(tx, loadTenantConfig(tx, appointment.TenantID), &appointment)
+			if err != nil {
+				status = code
+				return err
+			}
+			return tx.Omit("Service").Save(&appointment).Error
+		}); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, appointment)
+	}
+}
+
+// AppointmentStatusRequest - Request body for closing a booking that did not happen
+type AppointmentStatusRequest struct {
+	Status string `json:"status" binding:"required"` // no_show, cancelled
+}
+
+// UpdateAppointmentStatus - PATCH /api/appointments/:id/status
+// Frees the staff member's time. Use check-in when the customer arrives.
+func UpdateAppointmentStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req AppointmentStatusRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Status != "no_show" && req.Status != "cancelled" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be no_show or cancelled"})
+			return
+		}
+
+		appointment, ok := loadTenantAppointment(c, db)
+		if !ok {
+			return
+		}
+
+		result := db.Model(&models.Appointment{}).Where("id = ? AND status = ?", appointment.ID, "booked").Update("status", req.Status)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Appointment is " + appointment.Status})
+			return
+		}
+		appointment.Status = req.Status
+
+		c.JSON(http.StatusOK, appointment)
+	}
+}
+
+// CheckInAppointment - POST /api/appointments/:id/check-in
+// Opens an order for the booked service. Add-on services are added to it with
+// POST /orders/:id/items, and it is paid with POST /orders/:id/settle.
+func CheckInAppointment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appointment, ok := loadTenantAppointment(c, db)
+		if !ok {
+			return
+		}
+		if appointment.Status != "booked" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Appointment is " + appointment.Status})
+			return
+		}
+
+		tab := tabDetails{
+			Items: []OrderItemRequest{{
+				ProductID: appointment.Service.ProductID,
+				Quantity:  1,
+				LineID:    1,
+				Round:     1,
+				Note:      "With " + appointment.StaffName,
+			}},
+			Rounds:        1,
+			CustomerID:    appointment.CustomerID,
+			CustomerName:  appointment.CustomerName,
+			CustomerPhone: appointment.CustomerPhone,
+		}
+
+		tx := db.Begin()
+
+		orderReq := tab.request(appointment.TenantID)
+		quote, err := quoteOrder(c, tx, &orderReq)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		order := models.Order{
+			TenantID:   quote.TenantID,
+			CustomerID: orderReq.CustomerID,
+			Status:     "PENDING",
+			Total:      quote.Total,
+			Tax:        quote.Tax,
+			Details:    tabOrderDetails(models.Order{}, quote, orderReq, tab),
+		}
+		if err := tx.Create(&order).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+			return
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.Appointment{}).Where("id = ? AND status = ?", appointment.ID, "booked").Updates(map[string]interface{}{
+			"status":        "checked_in",
+			"checked_in_at": now,
+			"order_id":      order.ID,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Appointment has changed; reload it"})
+			return
+		}
+
+		tx.Commit()
+
+		response := tabResponse(order, quote, tab)
+		response["appointment_id"] = appointment.ID
+		c.JSON(http.StatusCreated, response)
+	}
+}
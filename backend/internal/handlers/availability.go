@@ -0,0 +1,425 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"ringpos-backend/internal/feed"
+	"ringpos-backend/internal/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// menuFeed carries menu availability changes to the terminals
+var menuFeed = feed.NewHub()
+
+// weekdays are the day names availability windows use
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// dayName shortens a day such as "Saturday" to the name windows use
+func dayName(day string) (string, bool) {
+	day = strings.ToLower(strings.TrimSpace(day))
+	if len(day) > 3 {
+		day = day[:3]
+	}
+	_, ok := weekdays[day]
+	return day, ok
+}
+
+// validClock reports whether clock is a time of day written HH:MM. Hours need two
+// digits because windows are compared as text.
+func validClock(clock string) bool {
+	_, err := time.Parse("15:04", clock)
+	return err == nil && len(clock) == 5
+}
+
+// onDay reports whether a window's days include day
+func onDay(days string, day time.Weekday) bool {
+	if days == "" {
+		return true
+	}
+	for _, name := range strings.Split(days, ",") {
+		if weekdays[strings.TrimSpace(name)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// inWindow reports whether a time on the tenant's clock falls in the window
+func inWindow(w models.ProductAvailability, t time.Time) bool {
+	clock := t.Format("15:04")
+	if w.StartTime != "" && w.EndTime != "" && w.EndTime <= w.StartTime {
+		// Past midnight: the evening belongs to today, the early hours to yesterday
+		if clock >= w.StartTime {
+			return onDay(w.Days, t.Weekday())
+		}
+		return clock < w.EndTime && onDay(w.Days, (t.Weekday()+6)%7)
+	}
+	return onDay(w.Days, t.Weekday()) &&
+		(w.StartTime == "" || clock >= w.StartTime) &&
+		(w.EndTime == "" || clock < w.EndTime)
+}
+
+// MenuAvailability - Whether a product can be sold right now, and why not
+type MenuAvailability struct {
+	ProductID uint   `json:"product_id"`
+	Sellable  bool   `json:"sellable"`
+	Reason    string `json:"reason,omitempty"` // sold_out, unavailable (outside its windows), out_of_stock
+}
+
+// menuAvailability works out which products can be sold at now. Variants follow
+// the 86 and windows of their parent; a product made to a recipe is out of stock
+// when an ingredient has too little left for one more.
+func menuAvailability(db *gorm.DB, products []models.Product, loc *time.Location, now time.Time) map[uint]MenuAvailability {
+	ids := make([]uint, 0, len(products))
+	parentIDs := []uint{}
+	for _, p := range products {
+		ids = append(ids, p.ID)
+		if p.ParentID != nil {
+			parentIDs = append(parentIDs, *p.ParentID)
+		}
+	}
+
+	soldOutParents := map[uint]bool{}
+	if len(parentIDs) > 0 {
+		var parents []models.Product
+		db.Select("id", "sold_out").Where("id IN ?", parentIDs).Find(&parents)
+		for _, p := range parents {
+			soldOutParents[p.ID] = p.SoldOut
+		}
+	}
+
+	var windows []models.ProductAvailability
+	db.Where("product_id IN ? OR product_id IN ?", ids, parentIDs).Find(&windows)
+	windowsOf := map[uint][]models.ProductAvailability{}
+	for _, w := range windows {
+		windowsOf[w.ProductID] = append(windowsOf[w.ProductID], w)
+	}
+
+	// A parent's own stock is not sold, its variants' is; services sell time, not stock
+	var unstocked []uint
+	db.Model(&models.Product{}).Where("parent_id IN ?", ids).Distinct().Pluck("parent_id", &unstocked)
+	var services []uint
+	db.Model(&models.ServiceDefinition{}).Where("product_id IN ?", ids).Pluck("product_id", &services)
+	noStock := map[uint]bool{}
+	for _, id := range append(unstocked, services...) {
+		noStock[id] = true
+	}
+
+	var recipes []models.RecipeLine
+	db.Where("product_id IN ?", ids).Preload("Ingredient").Find(&recipes)
+	recipeOf := map[uint][]models.RecipeLine{}
+	for _, line := range recipes {
+		recipeOf[line.ProductID] = append(recipeOf[line.ProductID], line)
+	}
+
+	local := now.In(loc)
+	result := make(map[uint]MenuAvailability, len(products))
+	for _, p := range products {
+		state := MenuAvailability{ProductID: p.ID, Sellable: true}
+		own := windowsOf[p.ID]
+		if len(own) == 0 && p.ParentID != nil {
+			own = windowsOf[*p.ParentID]
+		}
+
+		switch {
+		case p.SoldOut || (p.ParentID != nil && soldOutParents[*p.ParentID]):
+			state.Reason = "sold_out"
+		case len(own) > 0 && !anyWindow(own, local):
+			state.Reason = "unavailable"
+		case len(recipeOf[p.ID]) > 0:
+			for _, line := range recipeOf[p.ID] {
+				if line.Ingredient.Stock < line.Quantity {
+					state.Reason = "out_of_stock"
+				}
+			}
+		case !noStock[p.ID] && p.Stock <= 0:
+			state.Reason = "out_of_stock"
+		}
+		state.Sellable = state.Reason == ""
+		result[p.ID] = state
+	}
+	return result
+}
+
+// anyWindow reports whether t falls in one of the windows
+func anyWindow(windows []models.ProductAvailability, t time.Time) bool {
+	for _, w := range windows {
+		if inWindow(w, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// sellableProducts keeps the products that can be sold now. A parent stays
+// while one of its variants can be sold, listing only those variants.
+func sellableProducts(db *gorm.DB, products []models.Product) []models.Product {
+	// Products and their variants are worked out together, in one go per tenant
+	byTenant := map[uint][]models.Product{}
+	for _, p := range products {
+		byTenant[p.TenantID] = append(byTenant[p.TenantID], p)
+		byTenant[p.TenantID] = append(byTenant[p.TenantID], p.Variants...)
+	}
+	now := time.Now()
+	states := map[uint]MenuAvailability{}
+	for tenantID, all := range byTenant {
+		for id, state := range menuAvailability(db, all, loadTenantConfig(db, tenantID).location(), now) {
+			states[id] = state
+		}
+	}
+
+	result := make([]models.Product, 0, len(products))
+	for _, p := range products {
+		if len(p.Variants) > 0 {
+			variants := make([]models.Product, 0, len(p.Variants))
+			for _, v := range p.Variants {
+				if states[v.ID].Sellable {
+					variants = append(variants, v)
+				}
+			}
+			if len(variants) == 0 {
+				continue
+			}
+			p.Variants = variants
+		}
+		if states[p.ID].Sellable {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// checkSellable makes sure every item of a new order can be sold now
+func checkSellable(db *gorm.DB, tenantID uint, items []pricedItem) error {
+	products := make([]models.Product, len(items))
+	for i, item := range items {
+		products[i] = item.Product
+	}
+	states := menuAvailability(db, products, loadTenantConfig(db, tenantID).location(), time.Now())
+
+	for _, item := range items {
+		switch states[item.Product.ID].Reason {
+		case "sold_out":
+			return errors.New(item.Product.Name + " is sold out")
+		case "unavailable":
+			return errors.New(item.Product.Name + " is not on the menu at this time")
+		case "out_of_stock":
+			return errors.New(item.Product.Name + " is out of stock")
+		}
+	}
+	return nil
+}
+
+// publishAvailability tells the terminals whether the products, and the menu
+// items made from them, can be sold now
+func publishAvailability(db *gorm.DB, tenantID uint, productIDs []uint) {
+	if len(productIDs) == 0 {
+		return
+	}
+	var products []models.Product
+	db.Where("tenant_id = ?", tenantID).
+		Where("id IN ? OR id IN (?)", productIDs,
+			db.Model(&models.RecipeLine{}).Select("product_id").Where("ingredient_id IN ?", productIDs)).
+		Find(&products)
+
+	states := menuAvailability(db, products, loadTenantConfig(db, tenantID).location(), time.Now())
+	for _, p := range products {
+		menuFeed.Publish(tenantID, feed.Event{Type: "availability", Data: states[p.ID]})
+	}
+}
+
+// publishStockOuts announces the products an order used the last of, along
+// with the ingredients its recipes and modifiers took
+func publishStockOuts(db *gorm.DB, quote orderQuote) {
+	var used []uint
+	for _, item := range quote.Items {
+		used = append(used, item.Product.ID)
+		for _, option := range item.Options {
+			if option.StockProductID != nil {
+				used = append(used, *option.StockProductID)
+			}
+		}
+	}
+	if len(used) == 0 {
+		return
+	}
+
+	var ranOut []uint
+	db.Model(&models.Product{}).
+		Where("stock <= 0 AND (id IN ? OR id IN (?))", used,
+			db.Model(&models.RecipeLine{}).Select("ingredient_id").Where("product_id IN ?", used)).
+		Pluck("id", &ranOut)
+	publishAvailability(db, quote.TenantID, ranOut)
+}
+
+// GetMenuAvailability - GET /api/products/availability
+// Whether each of the tenant's products can be sold right now, for terminals
+// to load before following GET /menu/stream.
+func GetMenuAvailability(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		var products []models.Product
+		if err := db.Where("tenant_id = ?", *tenantID.(*uint)).Preload("Variants").Find(&products).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		states := menuAvailability(db, products, loadTenantConfig(db, *tenantID.(*uint)).location(), time.Now())
+		result := make([]MenuAvailability, len(products))
+		for i, p := range products {
+			result[i] = states[p.ID]
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// StreamMenuAvailability - GET /api/menu/stream
+// Server-Sent Events: an "availability" event each time a product is 86'd or
+// cleared, has its windows changed, runs out of stock or is restocked.
+func StreamMenuAvailability(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		streamEvents(c, menuFeed, func(feed.Event) bool { return true })
+	}
+}
+
+// SetSoldOut - PATCH /api/products/:id/sold-out
+// 86's an item, or puts it back on sale, on every terminal.
+func SetSoldOut(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			SoldOut bool `json:"sold_out"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		if err := db.Model(&product).Update("sold_out", req.SoldOut).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Variants follow their parent
+		ids := []uint{}
+		db.Model(&models.Product{}).Where("parent_id = ?", product.ID).Pluck("id", &ids)
+		publishAvailability(db, product.TenantID, append(ids, product.ID))
+
+		state := menuAvailability(db, []models.Product{product}, loadTenantConfig(db, product.TenantID).location(), time.Now())
+		c.JSON(http.StatusOK, state[product.ID])
+	}
+}
+
+// AvailabilityWindowRequest - One window a product is sold in
+type AvailabilityWindowRequest struct {
+	Days      []string `json:"days"`       // e.g. ["sat","sun"]; empty for every day
+	StartTime string   `json:"start_time"` // HH:MM; empty from midnight
+	EndTime   string   `json:"end_time"`   // HH:MM, exclusive; empty to midnight
+}
+
+// validate normalizes the days and checks the times
+func (req *AvailabilityWindowRequest) validate() error {
+	for i, day := range req.Days {
+		normalized, ok := dayName(day)
+		if !ok {
+			return errors.New("days must be mon, tue, wed, thu, fri, sat or sun")
+		}
+		req.Days[i] = normalized
+	}
+	for _, clock := range []string{req.StartTime, req.EndTime} {
+		if clock != "" && !validClock(clock) {
+			return errors.New("times must be HH:MM, e.g. 06:30")
+		}
+	}
+	return nil
+}
+
+// GetProductAvailability - GET /api/products/:id/availability
+func GetProductAvailability(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		var windows []models.ProductAvailability
+		if err := db.Where("product_id = ?", product.ID).Order("id ASC").Find(&windows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, windows)
+	}
+}
+
+// SetProductAvailability - PUT /api/products/:id/availability
+// Replaces the product's windows; an empty list puts it on the menu all day, every day.
+func SetProductAvailability(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Windows []AvailabilityWindowRequest `json:"windows"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range req.Windows {
+			if err := req.Windows[i].validate(); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		windows := make([]models.ProductAvailability, len(req.Windows))
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("product_id = ?", product.ID).Delete(&models.ProductAvailability{}).Error; err != nil {
+				return err
+			}
+			for i, w := range req.Windows {
+				windows[i] = models.ProductAvailability{
+					TenantID:  product.TenantID,
+					ProductID: product.ID,
+					Days:      strings.Join(w.Days, ","),
+					StartTime: w.StartTime,
+					EndTime:   w.EndTime,
+				}
+				if err := tx.Create(&windows[i]).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ids := []uint{}
+		db.Model(&models.Product{}).Where("parent_id = ?", product.ID).Pluck("id", &ids)
+		publishAvailability(db, product.TenantID, append(ids, product.ID))
+
+		c.JSON(http.StatusOK, windows)
+	}
+}
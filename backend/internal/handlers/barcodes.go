@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"ringpos-backend/internal/barcode"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// skuTaken reports whether another product of the tenant already uses sku
+func skuTaken(db *gorm.DB, tenantID uint, sku string, excludeID uint) bool {
+	if sku == "" {
+		return false
+	}
+	var count int64
+	db.Model(&models.Product{}).Where("tenant_id = ? AND sku = ? AND id <> ?", tenantID, sku, excludeID).Count(&count)
+	return count > 0
+}
+
+// buildProductBarcodes validates codes and checks that none is already used by the tenant
+func buildProductBarcodes(db *gorm.DB, tenantID uint, codes []string) ([]models.ProductBarcode, error) {
+	var barcodes []models.ProductBarcode
+	seen := map[string]bool{}
+	for _, raw := range codes {
+		code, kind, err := barcode.Classify(raw)
+		if err != nil {
+			return nil, errors.New("barcode " + raw + ": " + err.Error())
+		}
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+
+		var count int64
+		db.Model(&models.ProductBarcode{}).Where("tenant_id = ? AND code = ?", tenantID, code).Count(&count)
+		if count > 0 {
+			return nil, errors.New("barcode " + code + " is already assigned to another product")
+		}
+
+		barcodes = append(barcodes, models.ProductBarcode{TenantID: tenantID, Code: code, Type: kind})
+	}
+	return barcodes, nil
+}
+
+// splitBarcodes splits a list of barcodes separated by "|" as used in CSV imports
+func splitBarcodes(value string) []string {
+	var codes []string
+	for _, code := range strings.Split(value, "|") {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// LookupProduct - GET /api/products/lookup?barcode= or ?sku=
+func LookupProduct(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		code := strings.TrimSpace(c.Query("barcode"))
+		sku := strings.TrimSpace(c.Query("sku"))
+		if code == "" && sku == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "barcode or sku is required"})
+			return
+		}
+
+		var tenantID uint
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tid, exists := c.Get("tenant_id")
+			if exists && tid != nil {
+				tenantID = *tid.(*uint)
+			}
+		} else if tid, err := strconv.Atoi(c.Query("tenant_id")); err == nil {
+			// Superadmin looks up within the given tenant
+			tenantID = uint(tid)
+		}
+
+		var product models.Product
+		if code == "" {
+			if err := db.Preload("Barcodes").Where("tenant_id = ? AND sku = ?", tenantID, sku).First(&product).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "No product with this SKU"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"product": product, "quantity": 1})
+			return
+		}
+
+		var match models.ProductBarcode
+		if err := db.Where("tenant_id = ? AND code = ?", tenantID, code).First(&match).Error; err == nil {
+			if err := db.Preload("Barcodes").First(&product, match.ProductID).Error; err == nil {
+				c.JSON(http.StatusOK, gin.H{"product": product, "barcode": code, "quantity": 1})
+				return
+			}
+		}
+
+		// Not a registered barcode, try an in-store label with embedded weight or price
+		scale, ok := barcode.ParseScale(code, loadTenantConfig(db, tenantID).ScaleBarcodes)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No product with this barcode"})
+			return
+		}
+
+		itemCode := strings.TrimLeft(scale.ItemCode, "0")
+		if err := db.Preload("Barcodes").
+			Where("tenant_id = ? AND plu <> '' AND (plu = ? OR plu = ?)", tenantID, scale.ItemCode, itemCode).
+			First(&product).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No product with PLU " + scale.ItemCode})
+			return
+		}
+
+		response := gin.H{
+			"product": product,
+			"barcode": code,
+			"scale":   scale,
+		}
+		if scale.ValueType == barcode.Price {
+			// The label carries the line total; derive the quantity from the unit price
+			lineTotal := money.FromFloat(scale.Value)
+			quantity := 1.0
+			if product.Price > 0 {
+				quantity = roundQty(float64(lineTotal) / float64(product.Price))
+			}
+			response["quantity"] = quantity
+			response["line_total"] = lineTotal
+		} else {
+			response["quantity"] = scale.Value
+			response["line_total"] = product.Price.MulQty(scale.Value)
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// AddProductBarcode - POST /api/products/:id/barcodes
+func AddProductBarcode(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var req struct {
+			Code string `json:"code" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var product models.Product
+		if err := db.First(&product, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
+
+		// Verify tenant ownership (non-superadmin)
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if !exists || tenantID == nil || product.TenantID != *tenantID.(*uint) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+		}
+
+		barcodes, err := buildProductBarcodes(db, product.TenantID, []string{req.Code})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		productBarcode := barcodes[0]
+		productBarcode.ProductID = product.ID
+		if err := db.Create(&productBarcode).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add barcode"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, productBarcode)
+	}
+}
+
+// DeleteProductBarcode - DELETE /api/products/:id/barcodes/:barcodeId
+func DeleteProductBarcode(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var productBarcode models.ProductBarcode
+		if err := db.Where("id = ? AND product_id = ?", c.Param("barcodeId"), c.Param("id")).First(&productBarcode).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Barcode not found"})
+			return
+		}
+
+		// Verify tenant ownership (non-superadmin)
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if !exists || tenantID == nil || productBarcode.TenantID != *tenantID.(*uint) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+		}
+
+		if err := db.Delete(&productBarcode).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Barcode removed"})
+	}
+}
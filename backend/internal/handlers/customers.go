@@ -1,43 +1,136 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"ringpos-backend/internal/phone"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+// CustomerMetrics - A customer's lifetime figures from their orders
+type CustomerMetrics struct {
+	TotalSpend    money.Amount `json:"total_spend"`
+	Visits        int64        `json:"visits"`
+	AverageBasket money.Amount `json:"average_basket" gorm:"-"`
+	FirstVisit    *time.Time   `json:"first_visit"`
+	LastVisit     *time.Time   `json:"last_visit"`
+}
+
+// CustomerWithMetrics - A customer in the customer list, with lifetime figures
+type CustomerWithMetrics struct {
+	models.Customer
+	CustomerMetrics
+}
+
+// customerOrderStats totals paid orders per customer, for joining onto customers
+// with paidOrderStatuses as its argument. Visit times come from the first and last
+// orders joined by ID, since SQLite returns MIN/MAX of a datetime as text.
+const customerOrderStats = `(SELECT customer_id, SUM(total) AS total_spend, COUNT(*) AS visits,
+	MIN(id) AS first_order_id, MAX(id) AS last_order_id
+	FROM orders WHERE deleted_at IS NULL AND customer_id IS NOT NULL AND status IN ? GROUP BY customer_id) AS stats`
+
+// customerSorts are the columns GetCustomers can sort by
+var customerSorts = map[string]string{
+	"name":        "customers.name",
+	"created_at":  "customers.created_at",
+	"total_spend": "total_spend",
+	"visits":      "visits",
+	"last_visit":  "last_order.created_at",
+	"points":      "customers.points",
+}
+
 // GetCustomers - GET /api/customers
+// ?sort=total_spend|visits|last_visit|name|points&order=asc|desc, and segments:
+// ?inactive_days=60 (not seen in 60 days), ?min_spend=, ?min_visits=
 func GetCustomers(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var customers []models.Customer
+		var customers []CustomerWithMetrics
 		
-		query := db.Order("created_at DESC")
+		query := db.Table("customers").
+			Select("customers.*, COALESCE(stats.total_spend, 0) AS total_spend, COALESCE(stats.visits, 0) AS visits, " +
+				"first_order.created_at AS first_visit, last_order.created_at AS last_visit").
+			Joins("LEFT JOIN "+customerOrderStats+" ON stats.customer_id = customers.id", paidOrderStatuses).
+			Joins("LEFT JOIN orders AS first_order ON first_order.id = stats.first_order_id").
+			Joins("LEFT JOIN orders AS last_order ON last_order.id = stats.last_order_id").
+			Where("customers.deleted_at IS NULL")
+
+		sortColumn, ok := customerSorts[c.DefaultQuery("sort", "created_at")]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be name, created_at, total_spend, visits, last_visit or points"})
+			return
+		}
+		direction := " DESC"
+		if c.Query("order") == "asc" {
+			direction = " ASC"
+		}
+		query = query.Order(sortColumn + direction)
 		
 		// Tenant isolation
 		role, _ := c.Get("role")
 		if role != "superadmin" {
 			tenantID, exists := c.Get("tenant_id")
 			if exists && tenantID != nil {
-				query = query.Where("tenant_id = ?", *tenantID.(*uint))
+				query = query.Where("customers.tenant_id = ?", *tenantID.(*uint))
 			}
 		} else {
 			if tenantID := c.Query("tenant_id"); tenantID != "" {
-				query = query.Where("tenant_id = ?", tenantID)
+				query = query.Where("customers.tenant_id = ?", tenantID)
 			}
 		}
 		
+		// Filter by customer group
+		if group := c.Query("customer_group"); group != "" {
+			query = query.Where("customer_group = ?", group)
+		}
+
 		// Search
 		if search := c.Query("search"); search != "" {
+			// Phones are stored in E.164, so "0812..." is looked up as "62812..."
+			phoneSearch := search
+			countryCode := phone.DefaultCountryCode
+			if tenantID, exists := c.Get("tenant_id"); exists && tenantID != nil {
+				countryCode = loadTenantConfig(db, *tenantID.(*uint)).PhoneCountryCode
+			}
+			if term, ok := phone.SearchTerm(search, countryCode); ok {
+				phoneSearch = term
+			}
 			query = query.Where("name LIKE ? OR phone LIKE ? OR email LIKE ?", 
-				"%"+search+"%", "%"+search+"%", "%"+search+"%")
+				"%"+search+"%", "%"+phoneSearch+"%", "%"+search+"%")
 		}
 
-		if err := query.Find(&customers).Error; err != nil {
+		// Segments. Customers who never ordered count as not seen since they signed up.
+		if days, err := strconv.Atoi(c.Query("inactive_days")); err == nil && days > 0 {
+			cutoff := time.Now().AddDate(0, 0, -days)
+			query = query.Where("(last_order.created_at < ? OR (last_order.id IS NULL AND customers.created_at < ?))", cutoff, cutoff)
+		}
+		if minSpend := c.Query("min_spend"); minSpend != "" {
+			amount, err := money.Parse(minSpend)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "min_spend must be an amount"})
+				return
+			}
+			query = query.Where("COALESCE(stats.total_spend, 0) >= ?", amount)
+		}
+		if minVisits, err := strconv.Atoi(c.Query("min_visits")); err == nil {
+			query = query.Where("COALESCE(stats.visits, 0) >= ?", minVisits)
+		}
+
+		if err := query.Scan(&customers).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		for i := range customers {
+			customers[i].AverageBasket = averageBasket(customers[i].TotalSpend, customers[i].Visits)
+		}
 
 		c.JSON(http.StatusOK, customers)
 	}
@@ -70,13 +163,154 @@ func GetCustomer(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// averageBasket is the average order value over a number of visits
+func averageBasket(spend money.Amount, visits int64) money.Amount {
+	if visits == 0 {
+		return 0
+	}
+	return money.FromFloat(spend.Float() / float64(visits))
+}
+
+// favoriteProduct is a product a customer buys, totalled over their orders
+type favoriteProduct struct {
+	ProductID uint         `json:"product_id"`
+	Name      string       `json:"name"`
+	Quantity  float64      `json:"quantity"`
+	Orders    int          `json:"orders"`
+	Spend     money.Amount `json:"spend"` // After discounts, before taxes
+}
+
+// favoriteProducts totals the lines in the order details by product, most bought first
+func favoriteProducts(orders []models.Order, limit int) []favoriteProduct {
+	byProduct := map[uint]*favoriteProduct{}
+	for _, order := range orders {
+		var details struct {
+			Items []OrderItemRequest `json:"items"`
+		}
+		if err := json.Unmarshal([]byte(order.Details), &details); err != nil {
+			continue
+		}
+
+		seen := map[uint]bool{}
+		for _, item := range details.Items {
+			fav, ok := byProduct[item.ProductID]
+			if !ok {
+				fav = &favoriteProduct{ProductID: item.ProductID, Name: item.Name}
+				byProduct[item.ProductID] = fav
+			}
+			fav.Quantity = roundQty(fav.Quantity + item.Quantity)
+			fav.Spend += item.Subtotal - item.Discount
+			if !seen[item.ProductID] {
+				seen[item.ProductID] = true
+				fav.Orders++
+			}
+		}
+	}
+
+	favorites := make([]favoriteProduct, 0, len(byProduct))
+	for _, fav := range byProduct {
+		favorites = append(favorites, *fav)
+	}
+	sort.Slice(favorites, func(a, b int) bool {
+		if favorites[a].Orders != favorites[b].Orders {
+			return favorites[a].Orders > favorites[b].Orders
+		}
+		if favorites[a].Spend != favorites[b].Spend {
+			return favorites[a].Spend > favorites[b].Spend
+		}
+		return favorites[a].ProductID < favorites[b].ProductID
+	})
+	if len(favorites) > limit {
+		favorites = favorites[:limit]
+	}
+	return favorites
+}
+
+// GetCustomerSummary - GET /api/customers/:id/summary
+// Lifetime spend, visits, average basket, favorite products and the customer's orders.
+func GetCustomerSummary(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		customer, ok := loadTenantCustomer(c, db)
+		if !ok {
+			return
+		}
+
+		var orders []models.Order
+		if err := db.Where("customer_id = ?", customer.ID).Order("created_at DESC").Find(&orders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Open tabs and bills merged into another are listed but not counted
+		var paid []models.Order
+		for _, order := range orders {
+			if isPaidOrder(order) {
+				paid = append(paid, order)
+			}
+		}
+
+		metrics := CustomerMetrics{Visits: int64(len(paid))}
+		for i, order := range paid {
+			metrics.TotalSpend += order.Total
+			if i == 0 {
+				metrics.LastVisit = &paid[i].CreatedAt
+			}
+			metrics.FirstVisit = &paid[i].CreatedAt
+		}
+		metrics.AverageBasket = averageBasket(metrics.TotalSpend, metrics.Visits)
+
+		c.JSON(http.StatusOK, gin.H{
+			"customer":          customer,
+			"total_spend":       metrics.TotalSpend,
+			"visits":            metrics.Visits,
+			"average_basket":    metrics.AverageBasket,
+			"first_visit":       metrics.FirstVisit,
+			"last_visit":        metrics.LastVisit,
+			"favorite_products": favoriteProducts(paid, 5),
+			"orders":            orders,
+		})
+	}
+}
+
+// normalize cleans up the contact fields: phones become E.164 using the tenant's
+// country code and emails are lowercased
+func (req *CreateCustomerRequest) normalize(db *gorm.DB, tenantID uint) error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	req.Phone = strings.TrimSpace(req.Phone)
+	if req.Phone == "" {
+		return nil
+	}
+	normalized, err := phone.Normalize(req.Phone, loadTenantConfig(db, tenantID).PhoneCountryCode)
+	if err != nil {
+		return errors.New("Invalid phone number " + req.Phone)
+	}
+	req.Phone = normalized
+	return nil
+}
+
+// customerWithPhone returns the ID of another customer of the tenant with the phone, or 0
+func customerWithPhone(db *gorm.DB, tenantID uint, phone string, excludeID uint) uint {
+	if phone == "" {
+		return 0
+	}
+	var existing models.Customer
+	if err := db.Select("id").Where("tenant_id = ? AND phone = ? AND id <> ?", tenantID, phone, excludeID).First(&existing).Error; err != nil {
+		return 0
+	}
+	return existing.ID
+}
+
 // CreateCustomerRequest - Request body for creating customer
 type CreateCustomerRequest struct {
-	Name    string `json:"name" binding:"required"`
-	Phone   string `json:"phone"`
-	Email   string `json:"email"`
-	Address string `json:"address"`
-	Notes   string `json:"notes"`
+	Name          string `json:"name" binding:"required"`
+	Phone         string `json:"phone"`
+	Email         string `json:"email"`
+	Address       string `json:"address"`
+	Notes         string `json:"notes"`
+	CustomerGroup string `json:"customer_group"` // retail (default), wholesale or member
+
+	CreditLimit *money.Amount `json:"credit_limit" binding:"omitempty,gte=0"` // Owner only; unchanged when omitted
 }
 
 // CreateCustomer - POST /api/customers
@@ -98,13 +332,39 @@ func CreateCustomer(db *gorm.DB) gin.HandlerFunc {
 			}
 		}
 
+		if err := req.normalize(db, tenantID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if existingID := customerWithPhone(db, tenantID, req.Phone, 0); existingID != 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "A customer with this phone already exists", "customer_id": existingID})
+			return
+		}
+
+		if req.CreditLimit != nil && role != "owner" && role != "superadmin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can set a credit limit"})
+			return
+		}
+
+		if req.CustomerGroup == "" {
+			req.CustomerGroup = "retail"
+		}
+		if !customerGroups[req.CustomerGroup] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "customer_group must be retail, wholesale or member"})
+			return
+		}
+
 		customer := models.Customer{
-			TenantID: tenantID,
-			Name:     req.Name,
-			Phone:    req.Phone,
-			Email:    req.Email,
-			Address:  req.Address,
-			Notes:    req.Notes,
+			TenantID:      tenantID,
+			Name:          req.Name,
+			Phone:         req.Phone,
+			Email:         req.Email,
+			Address:       req.Address,
+			Notes:         req.Notes,
+			CustomerGroup: req.CustomerGroup,
+		}
+		if req.CreditLimit != nil {
+			customer.CreditLimit = *req.CreditLimit
 		}
 
 		if err := db.Create(&customer).Error; err != nil {
@@ -145,11 +405,34 @@ func UpdateCustomer(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if err := req.normalize(db, customer.TenantID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if existingID := customerWithPhone(db, customer.TenantID, req.Phone, customer.ID); existingID != 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "A customer with this phone already exists", "customer_id": existingID})
+			return
+		}
+
 		customer.Name = req.Name
 		customer.Phone = req.Phone
 		customer.Email = req.Email
 		customer.Address = req.Address
 		customer.Notes = req.Notes
+		if req.CustomerGroup != "" {
+			if !customerGroups[req.CustomerGroup] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "customer_group must be retail, wholesale or member"})
+				return
+			}
+			customer.CustomerGroup = req.CustomerGroup
+		}
+		if req.CreditLimit != nil {
+			if role != "owner" && role != "superadmin" {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can set a credit limit"})
+				return
+			}
+			customer.CreditLimit = *req.CreditLimit
+		}
 
 		if err := db.Save(&customer).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
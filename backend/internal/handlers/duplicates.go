@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// duplicateNameScore is how similar two names must be to suggest a match
+const duplicateNameScore = 0.85
+
+// normalizeName lowercases a name and keeps only letters, digits and single spaces
+func normalizeName(name string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '.' || r == ',' || r == '-':
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// levenshtein is the number of single character edits between two strings
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// nameSimilarity scores two names from 0 to 1. Word order is ignored, so
+// "Budi Santoso" and "Santoso Budi" match.
+func nameSimilarity(a, b string) float64 {
+	a, b = normalizeName(a), normalizeName(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	sortWords := func(s string) string {
+		words := strings.Fields(s)
+		sort.Strings(words)
+		return strings.Join(words, " ")
+	}
+	a, b = sortWords(a), sortWords(b)
+
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// DuplicateMatch - Two customers that are probably the same person
+type DuplicateMatch struct {
+	Customers []models.Customer `json:"customers"`
+	Reasons   []string          `json:"reasons"` // phone, email, name
+	Score     float64           `json:"score"`   // 1 for the same phone
+}
+
+// matchCustomers compares two customers and returns why they look alike, if they do
+func matchCustomers(a, b models.Customer) (DuplicateMatch, bool) {
+	match := DuplicateMatch{Customers: []models.Customer{a, b}}
+	if a.Phone != "" && a.Phone == b.Phone {
+		match.Reasons = append(match.Reasons, "phone")
+		match.Score = 1
+	}
+	if a.Email != "" && strings.EqualFold(a.Email, b.Email) {
+		match.Reasons = append(match.Reasons, "email")
+		match.Score = max(match.Score, 0.95)
+	}
+	if score := nameSimilarity(a.Name, b.Name); score >= duplicateNameScore {
+		match.Reasons = append(match.Reasons, "name")
+		match.Score = max(match.Score, score)
+	}
+	return match, len(match.Reasons) > 0
+}
+
+// GetCustomerDuplicates - GET /api/customers/duplicates?customer_id=
+// Suggests pairs of customers that are likely the same person, best matches first.
+// With customer_id, only matches for that customer are listed.
+func GetCustomerDuplicates(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		var customers []models.Customer
+		if err := db.Where("tenant_id = ?", *tenantID.(*uint)).Order("id ASC").Find(&customers).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var onlyID uint
+		if id := c.Query("customer_id"); id != "" {
+			parsed, err := strconv.ParseUint(id, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid customer_id"})
+				return
+			}
+			onlyID = uint(parsed)
+		}
+
+		matches := []DuplicateMatch{}
+		for i := range customers {
+			for j := i + 1; j < len(customers); j++ {
+				if onlyID != 0 && customers[i].ID != onlyID && customers[j].ID != onlyID {
+					continue
+				}
+				if match, ok := matchCustomers(customers[i], customers[j]); ok {
+					matches = append(matches, match)
+				}
+			}
+		}
+		sort.SliceStable(matches, func(a, b int) bool { return matches[a].Score > matches[b].Score })
+
+		c.JSON(http.StatusOK, matches)
+	}
+}
+
+// MergeCustomersRequest - Request body for merging duplicates into a customer
+type MergeCustomersRequest struct {
+	DuplicateIDs []uint `json:"duplicate_ids" binding:"required,min=1"`
+}
+
+// moveOpenTabs points a duplicate's open tabs at the surviving customer. Tabs are
+// quoted again from their details, so the customer is changed there as well.
+func moveOpenTabs(tx *gorm.DB, duplicateID uint, survivor models.Customer, phone string) error {
+	var orders []models.Order
+	if err := tx.Where("customer_id = ? AND status = ?", duplicateID, "PENDING").Find(&orders).Error; err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		var details map[string]interface{}
+		if err := json.Unmarshal([]byte(order.Details), &details); err != nil {
+			return errors.New("Open order " + strconv.Itoa(int(order.ID)) + " has unreadable details")
+		}
+		details["customer_id"] = survivor.ID
+		details["customer_name"] = survivor.Name
+		details["customer_phone"] = phone
+		detailsJSON, _ := json.Marshal(details)
+		if err := tx.Model(&order).Update("details", string(detailsJSON)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeCustomer moves everything of a duplicate onto the surviving customer and deletes the duplicate
+func mergeCustomer(tx *gorm.DB, survivor *models.Customer, duplicate models.Customer) error {
+	// The survivor takes the duplicate's phone if it has none
+	phone := survivor.Phone
+	if phone == "" {
+		phone = duplicate.Phone
+	}
+
+	if err := moveOpenTabs(tx, duplicate.ID, *survivor, phone); err != nil {
+		return err
+	}
+	for _, table := range []string{"orders", "voucher_redemptions", "loyalty_entries", "receivable_entries"} {
+		if err := tx.Table(table).Where("customer_id = ?", duplicate.ID).Update("customer_id", survivor.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	// These keep the customer's name and phone as well, for slips and reminders
+	for _, table := range []string{"work_orders", "appointments"} {
+		if err := tx.Table(table).Where("customer_id = ?", duplicate.ID).Updates(map[string]interface{}{
+			"customer_id":    survivor.ID,
+			"customer_name":  survivor.Name,
+			"customer_phone": phone,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	// Store credit on one account pays off what is owed on the other
+	if owed, credit := max(survivor.Balance, duplicate.Balance), -min(survivor.Balance, duplicate.Balance); owed > 0 && credit > 0 {
+		if err := settleCharges(tx, survivor.ID, min(owed, credit)); err != nil {
+			return err
+		}
+	}
+
+	survivor.Points += duplicate.Points
+	survivor.Balance += duplicate.Balance
+	survivor.CreditLimit = max(survivor.CreditLimit, duplicate.CreditLimit)
+	survivor.Phone = phone
+	if survivor.Email == "" {
+		survivor.Email = duplicate.Email
+	}
+	if survivor.Address == "" {
+		survivor.Address = duplicate.Address
+	}
+	if duplicate.Notes != "" {
+		if survivor.Notes != "" {
+			survivor.Notes += "\n"
+		}
+		survivor.Notes += duplicate.Notes
+	}
+
+	// Free the duplicate's phone before it is kept with the survivor
+	if err := tx.Model(&duplicate).Update("phone", "").Error; err != nil {
+		return err
+	}
+	return tx.Delete(&duplicate).Error
+}
+
+// MergeCustomers - POST /api/customers/:id/merge
+// Moves the orders, work orders, appointments, voucher redemptions, points, account and notes of duplicates onto this customer.
+func MergeCustomers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req MergeCustomersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		survivor, ok := loadTenantCustomer(c, db)
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, id := range req.DuplicateIDs {
+				if id == survivor.ID {
+					return errors.New("A customer cannot be merged into itself")
+				}
+				var duplicate models.Customer
+				if err := tx.Where("id = ? AND tenant_id = ?", id, survivor.TenantID).First(&duplicate).Error; err != nil {
+					return errors.New("Customer " + strconv.Itoa(int(id)) + " not found")
+				}
+				if err := mergeCustomer(tx, &survivor, duplicate); err != nil {
+					return err
+				}
+			}
+			if err := tx.Save(&survivor).Error; err != nil {
+				return err
+			}
+			return updateLoyaltyTier(tx, loadTenantConfig(tx, survivor.TenantID).Loyalty, &survivor)
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, survivor)
+	}
+}
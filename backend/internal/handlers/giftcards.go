@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tenderGiftCard is the payment method that spends a gift card's balance
+const tenderGiftCard = "gift_card"
+
+// checkGiftCard finds a tenant's gift card by code and checks it can be spent
+func checkGiftCard(db *gorm.DB, tenantID uint, code string, now time.Time) (models.GiftCard, error) {
+	var card models.GiftCard
+	if err := db.Where("tenant_id = ? AND code = ?", tenantID, normalizeVoucherCode(code)).First(&card).Error; err != nil {
+		return card, errors.New("Gift card not found")
+	}
+	switch {
+	case card.Status != "active":
+		return card, errors.New("Gift card " + card.Code + " has been voided")
+	case card.ExpiresAt != nil && now.After(*card.ExpiresAt):
+		return card, errors.New("Gift card " + card.Code + " has expired")
+	}
+	return card, nil
+}
+
+// moveGiftCard adds entry.Amount to a card's balance and records it. Spending
+// is checked against the balance in the update itself, so a card cannot be
+// spent twice by two tills at once.
+func moveGiftCard(tx *gorm.DB, card *models.GiftCard, entry models.GiftCardEntry) error {
+	result := tx.Model(&models.GiftCard{}).
+		Where("id = ? AND status = ? AND balance + ? >= 0", card.ID, "active", entry.Amount).
+		UpdateColumn("balance", gorm.Expr("balance + ?", entry.Amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Not enough balance on gift card " + card.Code)
+	}
+	if err := tx.First(card, card.ID).Error; err != nil {
+		return err
+	}
+
+	entry.TenantID = card.TenantID
+	entry.GiftCardID = card.ID
+	entry.Balance = card.Balance
+	return tx.Create(&entry).Error
+}
+
+// loadTenantGiftCard fetches the gift card in the URL and checks tenant access
+func loadTenantGiftCard(c *gin.Context, db *gorm.DB) (models.GiftCard, bool) {
+	var card models.GiftCard
+	if err := db.First(&card, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Gift card not found"})
+		return card, false
+	}
+
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil || card.TenantID != *tenantID.(*uint) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return card, false
+		}
+	}
+
+	return card, true
+}
+
+// GetGiftCards - GET /api/gift-cards?code=&status=&customer_id=
+func GetGiftCards(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var cards []models.GiftCard
+
+		query := db.Order("created_at DESC")
+
+		// Tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if exists && tenantID != nil {
+				query = query.Where("tenant_id = ?", *tenantID.(*uint))
+			}
+		}
+
+		if code := c.Query("code"); code != "" {
+			query = query.Where("code = ?", normalizeVoucherCode(code))
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if customerID := c.Query("customer_id"); customerID != "" {
+			query = query.Where("customer_id = ?", customerID)
+		}
+
+		if err := query.Find(&cards).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, cards)
+	}
+}
+
+// GetGiftCard - GET /api/gift-cards/:id
+// Returns the card with its balance history, newest first.
+func GetGiftCard(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		card, ok := loadTenantGiftCard(c, db)
+		if !ok {
+			return
+		}
+
+		var entries []models.GiftCardEntry
+		if err := db.Where("gift_card_id = ?", card.ID).Order("created_at DESC, id DESC").Find(&entries).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"gift_card": card, "entries": entries})
+	}
+}
+
+// IssueGiftCardRequest - Request body for selling a gift card.
+// Leave code empty to generate one.
+type IssueGiftCardRequest struct {
+	Code          string       `json:"code"`
+	Amount        money.Amount `json:"amount" binding:"gt=0"`
+	PaymentMethod string       `json:"payment_method" binding:"required"`
+	CustomerID    *uint        `json:"customer_id"`
+	ExpiresAt     *time.Time   `json:"expires_at"`
+	Note          string       `json:"note"`
+}
+
+// GiftCardMoveRequest - Request body for topping up or redeeming a gift card
+type GiftCardMoveRequest struct {
+	Amount        money.Amount `json:"amount" binding:"gt=0"`
+	PaymentMethod string       `json:"payment_method"` // Required for a top-up
+	Note          string       `json:"note"`
+}
+
+// checkGiftCardPayment rejects paying for a gift card with store balances
+func checkGiftCardPayment(method string) error {
+	if method == tenderGiftCard || method == tenderOnAccount {
+		return errors.New("Gift cards must be paid for with cash, card or transfer")
+	}
+	return nil
+}
+
+// IssueGiftCard - POST /api/gift-cards
+// Selling a card does not create an order: the money is owed to the holder
+// until the card is spent, and only then counts as a sale.
+func IssueGiftCard(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req IssueGiftCardRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkGiftCardPayment(req.PaymentMethod); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		card := models.GiftCard{TenantID: *tenantID.(*uint), CustomerID: req.CustomerID, Status: "active", ExpiresAt: req.ExpiresAt}
+		if req.CustomerID != nil {
+			var count int64
+			db.Model(&models.Customer{}).Where("id = ? AND tenant_id = ?", *req.CustomerID, card.TenantID).Count(&count)
+			if count == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Customer not found"})
+				return
+			}
+		}
+
+		// A printed card brings its own code; otherwise one is drawn until it is free
+		card.Code = normalizeVoucherCode(req.Code)
+		for {
+			code := card.Code
+			if code == "" {
+				code = randomVoucherCode("GC", 14)
+			}
+			var count int64
+			db.Model(&models.GiftCard{}).Where("tenant_id = ? AND code = ?", card.TenantID, code).Count(&count)
+			if count == 0 {
+				card.Code = code
+				break
+			}
+			if card.Code != "" {
+				c.JSON(http.StatusConflict, gin.H{"error": "Gift card code already exists"})
+				return
+			}
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&card).Error; err != nil {
+				return err
+			}
+			return moveGiftCard(tx, &card, models.GiftCardEntry{
+				Type:          "issue",
+				Amount:        req.Amount,
+				PaymentMethod: req.PaymentMethod,
+				Note:          req.Note,
+				UserID:        c.GetUint("user_id"),
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, card)
+	}
+}
+
+// TopUpGiftCard - POST /api/gift-cards/:id/top-up
+func TopUpGiftCard(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req GiftCardMoveRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.PaymentMethod == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "payment_method is required"})
+			return
+		}
+		if err := checkGiftCardPayment(req.PaymentMethod); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		card, ok := loadTenantGiftCard(c, db)
+		if !ok {
+			return
+		}
+		if _, err := checkGiftCard(db, card.TenantID, card.Code, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return moveGiftCard(tx, &card, models.GiftCardEntry{
+				Type:          "top_up",
+				Amount:        req.Amount,
+				PaymentMethod: req.PaymentMethod,
+				Note:          req.Note,
+				UserID:        c.GetUint("user_id"),
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, card)
+	}
+}
+
+// RedeemGiftCard - POST /api/gift-cards/:id/redeem
+// Spends balance outside an order. At the till, pay with a gift_card tender instead.
+func RedeemGiftCard(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req GiftCardMoveRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		card, ok := loadTenantGiftCard(c, db)
+		if !ok {
+			return
+		}
+		if _, err := checkGiftCard(db, card.TenantID, card.Code, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return moveGiftCard(tx, &card, models.GiftCardEntry{
+				Type:   "redeem",
+				Amount: -req.Amount,
+				Note:   req.Note,
+				UserID: c.GetUint("user_id"),
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, card)
+	}
+}
+
+// VoidGiftCard - POST /api/gift-cards/:id/void
+// Writes off the remaining balance, e.g. for a lost or refunded card.
+func VoidGiftCard(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		if role != "owner" && role != "superadmin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can void gift cards"})
+			return
+		}
+
+		var req struct {
+			Note string `json:"note"`
+		}
+		c.ShouldBindJSON(&req)
+
+		card, ok := loadTenantGiftCard(c, db)
+		if !ok {
+			return
+		}
+		if card.Status == "void" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Gift card is already void"})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := moveGiftCard(tx, &card, models.GiftCardEntry{
+				Type:   "void",
+				Amount: -card.Balance,
+				Note:   req.Note,
+				UserID: c.GetUint("user_id"),
+			}); err != nil {
+				return err
+			}
+			card.Status = "void"
+			return tx.Model(&card).UpdateColumn("status", "void").Error
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, card)
+	}
+}
@@ -51,6 +51,7 @@ func Login(db *gorm.DB) gin.HandlerFunc {
 		// Generate JWT token
 		claims := middleware.Claims{
 			UserID:   user.ID,
+			Username: user.Username,
 			TenantID: user.TenantID,
 			Role:     user.Role,
 			RegisteredClaims: jwt.RegisteredClaims{
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"net/http"
 	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
 	"strconv"
 	"strings"
 
@@ -13,11 +15,15 @@ import (
 
 // ImportProductRequest - Single product from CSV
 type ImportProductRequest struct {
-	Name     string  `json:"name"`
-	Price    float64 `json:"price"`
-	Stock    int     `json:"stock"`
-	Category string  `json:"category"`
-	ImageURL string  `json:"image_url"`
+	Name       string            `json:"name"`
+	Price      money.Amount      `json:"price"`
+	Stock      float64           `json:"stock"`
+	Category   string            `json:"category"`
+	ImageURL   string            `json:"image_url"`
+	SKU        string            `json:"sku"`
+	Barcode    string            `json:"barcode"`    // One or more barcodes separated by "|"
+	ParentSKU  string            `json:"parent_sku"` // Imports the row as a variant of this product
+	Attributes map[string]string `json:"attributes"` // Variant attributes, e.g. {"size":"M"}
 }
 
 // ImportProductsRequest - Request body for bulk import
@@ -26,6 +32,61 @@ type ImportProductsRequest struct {
 	CSVData  string                 `json:"csv_data"`
 }
 
+// importColumns is the CSV column order used when the file has no header row
+var importColumns = []string{"name", "price", "stock", "category", "image_url", "sku", "barcode", "parent_sku", "attributes"}
+
+// importHeaderAliases maps accepted header names to import columns
+var importHeaderAliases = map[string]string{
+	"name":       "name",
+	"product":    "name",
+	"nama":       "name",
+	"price":      "price",
+	"harga":      "price",
+	"stock":      "stock",
+	"stok":       "stock",
+	"category":   "category",
+	"kategori":   "category",
+	"image_url":  "image_url",
+	"image":      "image_url",
+	"sku":        "sku",
+	"barcode":    "barcode",
+	"parent_sku": "parent_sku",
+	"parent":     "parent_sku",
+	"attributes": "attributes",
+	"variant":    "attributes",
+}
+
+// importRow is a parsed product along with its source row for error messages
+type importRow struct {
+	Row     int
+	Product ImportProductRequest
+}
+
+// parseImportRecord reads one CSV record using the given column layout
+func parseImportRecord(columns []string, record []string) ImportProductRequest {
+	fields := map[string]string{}
+	for i, value := range record {
+		if i < len(columns) && columns[i] != "" {
+			fields[columns[i]] = strings.TrimSpace(value)
+		}
+	}
+
+	price, _ := money.Parse(fields["price"])
+	stock, _ := strconv.ParseFloat(fields["stock"], 64)
+
+	return ImportProductRequest{
+		Name:       fields["name"],
+		Price:      price,
+		Stock:      stock,
+		Category:   fields["category"],
+		ImageURL:   fields["image_url"],
+		SKU:        fields["sku"],
+		Barcode:    fields["barcode"],
+		ParentSKU:  fields["parent_sku"],
+		Attributes: parseVariantAttributes(fields["attributes"]),
+	}
+}
+
 // ImportProducts - POST /api/products/import
 func ImportProducts(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -45,80 +106,84 @@ func ImportProducts(db *gorm.DB) gin.HandlerFunc {
 			}
 		}
 
-		var products []models.Product
+		var rows []importRow
 		var importedCount int
 		var errors []string
 
 		// If CSV data provided, parse it
 		if req.CSVData != "" {
 			reader := csv.NewReader(strings.NewReader(req.CSVData))
+			reader.FieldsPerRecord = -1
 			records, err := reader.ReadAll()
 			if err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV format"})
 				return
 			}
 
-			// Skip header if present
+			// Use the header row to map columns if present, otherwise the fixed layout:
+			// Name, Price, Stock, Category, ImageURL, SKU, Barcode, Parent SKU, Attributes
+			columns := importColumns
 			startIdx := 0
 			if len(records) > 0 {
-				header := strings.ToLower(records[0][0])
+				header := strings.ToLower(strings.TrimSpace(records[0][0]))
 				if header == "name" || header == "product" || header == "nama" {
 					startIdx = 1
+					columns = make([]string, len(records[0]))
+					for i, h := range records[0] {
+						columns[i] = importHeaderAliases[strings.ToLower(strings.TrimSpace(h))]
+					}
 				}
 			}
 
 			for i := startIdx; i < len(records); i++ {
 				record := records[i]
-				if len(record) < 3 {
+				if startIdx == 0 && len(record) < 3 {
 					errors = append(errors, "Row "+strconv.Itoa(i+1)+": insufficient columns")
 					continue
 				}
-
-				// Parse: Name, Price, Stock, Category (optional), ImageURL (optional)
-				name := strings.TrimSpace(record[0])
-				price, _ := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
-				stock, _ := strconv.Atoi(strings.TrimSpace(record[2]))
-				
-				category := ""
-				if len(record) > 3 {
-					category = strings.TrimSpace(record[3])
-				}
-				
-				imageURL := ""
-				if len(record) > 4 {
-					imageURL = strings.TrimSpace(record[4])
-				}
-
-				if name == "" {
-					errors = append(errors, "Row "+strconv.Itoa(i+1)+": name is required")
-					continue
-				}
-
-				products = append(products, models.Product{
-					TenantID: tenantID,
-					Name:     name,
-					Price:    price,
-					Stock:    stock,
-					Category: category,
-					ImageURL: imageURL,
-				})
+				rows = append(rows, importRow{Row: i + 1, Product: parseImportRecord(columns, record)})
 			}
 		} else {
 			// Use JSON products array
-			for _, p := range req.Products {
-				products = append(products, models.Product{
-					TenantID: tenantID,
-					Name:     p.Name,
-					Price:    p.Price,
-					Stock:    p.Stock,
-					Category: p.Category,
-					ImageURL: p.ImageURL,
-				})
+			for i, p := range req.Products {
+				rows = append(rows, importRow{Row: i + 1, Product: p})
 			}
 		}
 
-		// Bulk insert products
-		for _, product := range products {
+		// Parents and plain products first, so variants can find them by SKU
+		var variantRows []importRow
+		for _, row := range rows {
+			p := row.Product
+			if p.ParentSKU != "" {
+				variantRows = append(variantRows, row)
+				continue
+			}
+
+			if p.Name == "" {
+				errors = append(errors, "Row "+strconv.Itoa(row.Row)+": name is required")
+				continue
+			}
+
+			if skuTaken(db, tenantID, p.SKU, 0) {
+				errors = append(errors, "Row "+strconv.Itoa(row.Row)+": SKU "+p.SKU+" already exists")
+				continue
+			}
+			barcodes, err := buildProductBarcodes(db, tenantID, splitBarcodes(p.Barcode))
+			if err != nil {
+				errors = append(errors, "Row "+strconv.Itoa(row.Row)+": "+err.Error())
+				continue
+			}
+
+			product := models.Product{
+				TenantID: tenantID,
+				Name:     p.Name,
+				Price:    p.Price,
+				Stock:    p.Stock,
+				Category: p.Category,
+				ImageURL: p.ImageURL,
+				SKU:      p.SKU,
+				Barcodes: barcodes,
+			}
 			if err := db.Create(&product).Error; err != nil {
 				errors = append(errors, "Failed to create: "+product.Name)
 			} else {
@@ -126,10 +191,81 @@ func ImportProducts(db *gorm.DB) gin.HandlerFunc {
 			}
 		}
 
+		for _, row := range variantRows {
+			p := row.Product
+
+			var parent models.Product
+			if err := db.Where("tenant_id = ? AND sku = ? AND parent_id IS NULL", tenantID, p.ParentSKU).First(&parent).Error; err != nil {
+				errors = append(errors, "Row "+strconv.Itoa(row.Row)+": parent SKU "+p.ParentSKU+" not found")
+				continue
+			}
+
+			variant := newVariant(parent, p.Attributes)
+			if p.Name != "" && p.Name != parent.Name {
+				variant.Name = p.Name
+			}
+			if p.Price > 0 {
+				variant.Price = p.Price
+			}
+			if p.Category != "" {
+				variant.Category = p.Category
+			}
+			if p.ImageURL != "" {
+				variant.ImageURL = p.ImageURL
+			}
+			if p.SKU != "" {
+				variant.SKU = p.SKU
+			}
+			variant.Stock = p.Stock
+
+			if skuTaken(db, tenantID, variant.SKU, 0) {
+				errors = append(errors, "Row "+strconv.Itoa(row.Row)+": SKU "+variant.SKU+" already exists")
+				continue
+			}
+			barcodes, err := buildProductBarcodes(db, tenantID, splitBarcodes(p.Barcode))
+			if err != nil {
+				errors = append(errors, "Row "+strconv.Itoa(row.Row)+": "+err.Error())
+				continue
+			}
+			variant.Barcodes = barcodes
+
+			// Record the attribute names on the parent so it lists them as options
+			mergeVariantOptions(&parent, p.Attributes)
+			db.Model(&parent).Update("variant_options", parent.VariantOptions)
+
+			if err := db.Create(&variant).Error; err != nil {
+				errors = append(errors, "Failed to create: "+variant.Name)
+			} else {
+				importedCount++
+			}
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"imported": importedCount,
-			"total":    len(products),
+			"total":    len(rows),
 			"errors":   errors,
 		})
 	}
 }
+
+// mergeVariantOptions adds attribute values to the parent's variant_options
+func mergeVariantOptions(parent *models.Product, attrs map[string]string) {
+	options := map[string][]string{}
+	if parent.VariantOptions != "" {
+		json.Unmarshal([]byte(parent.VariantOptions), &options)
+	}
+	for k, v := range attrs {
+		found := false
+		for _, existing := range options[k] {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			options[k] = append(options[k], v)
+		}
+	}
+	optionsJSON, _ := json.Marshal(options)
+	parent.VariantOptions = string(optionsJSON)
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"ringpos-backend/internal/feed"
+	"ringpos-backend/internal/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// kitchenFeed carries ticket changes to the kitchen screens
+var kitchenFeed = feed.NewHub()
+
+// kitchenStatuses are the steps of a kitchen item, in order
+var kitchenStatuses = map[string]int{"queued": 0, "preparing": 1, "ready": 2, "served": 3}
+
+// ticketStatus is the status of a ticket's least advanced item, ignoring voided ones
+func ticketStatus(items []models.KitchenTicketItem) string {
+	status := "void"
+	for _, item := range items {
+		if item.Status == "void" {
+			continue
+		}
+		if status == "void" || kitchenStatuses[item.Status] < kitchenStatuses[status] {
+			status = item.Status
+		}
+	}
+	return status
+}
+
+// createKitchenTickets sends the kitchen items of an order round to their
+// stations, one ticket per station
+func createKitchenTickets(tx *gorm.DB, order models.Order, tableNumber string, round int, items []pricedItem) ([]models.KitchenTicket, error) {
+	byStation := map[string]*models.KitchenTicket{}
+	var stations []string
+	for i, item := range items {
+		station := item.Product.KitchenStation
+		if station == "" {
+			continue
+		}
+		ticket := byStation[station]
+		if ticket == nil {
+			ticket = &models.KitchenTicket{
+				TenantID:    order.TenantID,
+				OrderID:     order.ID,
+				Station:     station,
+				Round:       round,
+				TableNumber: tableNumber,
+				Status:      "queued",
+			}
+			byStation[station] = ticket
+			stations = append(stations, station)
+		}
+
+		// Orders paid at once have no line IDs; their lines are numbered in order
+		lineID := item.LineID
+		if lineID == 0 {
+			lineID = i + 1
+		}
+		ticket.Items = append(ticket.Items, models.KitchenTicketItem{
+			LineID:    lineID,
+			ProductID: item.Product.ID,
+			Name:      item.Name,
+			Quantity:  item.Quantity,
+			Modifiers: modifierNames(item.Modifiers),
+			Note:      item.Note,
+			Status:    "queued",
+		})
+	}
+
+	tickets := make([]models.KitchenTicket, 0, len(stations))
+	for _, station := range stations {
+		if err := tx.Create(byStation[station]).Error; err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, *byStation[station])
+	}
+	return tickets, nil
+}
+
+// voidKitchenItems takes a voided order line off the kitchen tickets
+func voidKitchenItems(tx *gorm.DB, orderID uint, lineID int) ([]models.KitchenTicket, error) {
+	var tickets []models.KitchenTicket
+	if err := tx.Where("order_id = ?", orderID).Preload("Items").Find(&tickets).Error; err != nil {
+		return nil, err
+	}
+
+	var changed []models.KitchenTicket
+	for _, ticket := range tickets {
+		touched := false
+		for i := range ticket.Items {
+			if ticket.Items[i].LineID == lineID && ticket.Items[i].Status != "void" {
+				ticket.Items[i].Status = "void"
+				if err := tx.Model(&ticket.Items[i]).Update("status", "void").Error; err != nil {
+					return nil, err
+				}
+				touched = true
+			}
+		}
+		if !touched {
+			continue
+		}
+		ticket.Status = ticketStatus(ticket.Items)
+		if err := tx.Model(&ticket).Update("status", ticket.Status).Error; err != nil {
+			return nil, err
+		}
+		changed = append(changed, ticket)
+	}
+	return changed, nil
+}
+
+// moveKitchenTickets hands an order's tickets to another order, or to the same
+// one at a new table, renumbering the lines the way they were on the bill
+func moveKitchenTickets(tx *gorm.DB, fromOrderID, toOrderID uint, tableNumber string, lines map[int]int) ([]models.KitchenTicket, error) {
+	var tickets []models.KitchenTicket
+	if err := tx.Where("order_id = ?", fromOrderID).Preload("Items").Find(&tickets).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range tickets {
+		ticket := &tickets[i]
+		for j := range ticket.Items {
+			item := &ticket.Items[j]
+			lineID, ok := lines[item.LineID]
+			if !ok {
+				continue
+			}
+			item.LineID = lineID
+			if err := tx.Model(item).Update("line_id", lineID).Error; err != nil {
+				return nil, err
+			}
+		}
+		ticket.OrderID = toOrderID
+		ticket.TableNumber = tableNumber
+		if err := tx.Model(ticket).Updates(map[string]interface{}{"order_id": toOrderID, "table_number": tableNumber}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return tickets, nil
+}
+
+// publishTickets tells the kitchen screens about new or changed tickets
+func publishTickets(tickets []models.KitchenTicket) {
+	for _, ticket := range tickets {
+		kitchenFeed.Publish(ticket.TenantID, feed.Event{Type: "ticket", Data: ticket})
+	}
+}
+
+// setKitchenItemStatus moves items of a ticket to status and updates the ticket
+func setKitchenItemStatus(tx *gorm.DB, ticket *models.KitchenTicket, status string, itemID uint) error {
+	found := false
+	for i := range ticket.Items {
+		item := &ticket.Items[i]
+		if itemID != 0 && item.ID != itemID {
+			continue
+		}
+		found = true
+		if item.Status == "void" {
+			if itemID != 0 {
+				return errors.New("Item has been voided")
+			}
+			continue
+		}
+		item.Status = status
+		if err := tx.Model(item).Update("status", status).Error; err != nil {
+			return err
+		}
+	}
+	if !found {
+		return errors.New("Item not found on the ticket")
+	}
+
+	ticket.Status = ticketStatus(ticket.Items)
+	return tx.Model(ticket).Update("status", ticket.Status).Error
+}
+
+// loadTenantTicket fetches a ticket with its items and checks tenant access
+func loadTenantTicket(c *gin.Context, db *gorm.DB, id interface{}) (models.KitchenTicket, bool) {
+	var ticket models.KitchenTicket
+	if err := db.Preload("Items").First(&ticket, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+		return ticket, false
+	}
+
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil || ticket.TenantID != *tenantID.(*uint) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return ticket, false
+		}
+	}
+
+	return ticket, true
+}
+
+// GetKitchenTickets - GET /api/kitchen/tickets?station=&status=&order_id=
+// Without status, lists the tickets still being worked on, oldest first.
+func GetKitchenTickets(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var tickets []models.KitchenTicket
+
+		query := db.Preload("Items").Order("created_at ASC, id ASC")
+
+		// Tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if exists && tenantID != nil {
+				query = query.Where("tenant_id = ?", *tenantID.(*uint))
+			}
+		}
+
+		if station := c.Query("station"); station != "" {
+			query = query.Where("station = ?", station)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		} else {
+			query = query.Where("status NOT IN ?", []string{"served", "void"})
+		}
+		if orderID := c.Query("order_id"); orderID != "" {
+			query = query.Where("order_id = ?", orderID)
+		}
+
+		if err := query.Find(&tickets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, tickets)
+	}
+}
+
+// KitchenStatusRequest - Request body for moving a ticket or item along
+type KitchenStatusRequest struct {
+	Status string `json:"status" binding:"required"` // queued, preparing, ready, served
+}
+
+// UpdateKitchenTicketStatus - PATCH /api/kitchen/tickets/:id/status
+// Moves every item on the ticket, e.g. when the whole ticket is bumped.
+func UpdateKitchenTicketStatus(db *gorm.DB) gin.HandlerFunc {
+	return updateKitchenStatus(db, false)
+}
+
+// UpdateKitchenItemStatus - PATCH /api/kitchen/items/:id/status
+func UpdateKitchenItemStatus(db *gorm.DB) gin.HandlerFunc {
+	return updateKitchenStatus(db, true)
+}
+
+// updateKitchenStatus moves a ticket, or one item when perItem is set, to the requested status
+func updateKitchenStatus(db *gorm.DB, perItem bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req KitchenStatusRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, ok := kitchenStatuses[req.Status]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be queued, preparing, ready or served"})
+			return
+		}
+
+		ticketID := interface{}(c.Param("id"))
+		var itemID uint
+		if perItem {
+			var item models.KitchenTicketItem
+			if err := db.First(&item, c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+				return
+			}
+			ticketID, itemID = item.TicketID, item.ID
+		}
+
+		ticket, ok := loadTenantTicket(c, db, ticketID)
+		if !ok {
+			return
+		}
+
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return setKitchenItemStatus(tx, &ticket, req.Status, itemID)
+		}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		publishTickets([]models.KitchenTicket{ticket})
+
+		c.JSON(http.StatusOK, ticket)
+	}
+}
+
+// StreamKitchenTickets - GET /api/kitchen/stream?station=
+// Server-Sent Events: a "ticket" event with the whole ticket each time one is
+// created or changes. Screens load GET /kitchen/tickets first, then follow this.
+func StreamKitchenTickets(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		station := c.Query("station")
+		streamEvents(c, kitchenFeed, func(event feed.Event) bool {
+			ticket, ok := event.Data.(models.KitchenTicket)
+			return !ok || station == "" || ticket.Station == station
+		})
+	}
+}
+
+// streamEvents follows a hub for the tenant over Server-Sent Events until the
+// client goes away, sending the events keep lets through
+func streamEvents(c *gin.Context, hub *feed.Hub, keep func(feed.Event) bool) {
+	tenantID, exists := c.Get("tenant_id")
+	if !exists || tenantID == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+		return
+	}
+
+	events, stop := hub.Subscribe(*tenantID.(*uint))
+	defer stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// A comment now and then keeps proxies from closing a quiet stream
+	keepAlive := time.NewTicker(20 * time.Second)
+	defer keepAlive.Stop()
+
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			return true
+		case event := <-events:
+			if keep(event) {
+				c.SSEvent(event.Type, event.Data)
+			}
+			return true
+		}
+	})
+}
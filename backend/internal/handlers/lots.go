@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// roundQty rounds a stock quantity to 3 decimals (grams, millilitres) to keep
+// float arithmetic on weighed goods from drifting
+func roundQty(q float64) float64 {
+	return math.Round(q*1000) / 1000
+}
+
+// parseDate accepts either a plain date (2006-01-02) or an RFC3339 timestamp
+func parseDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New("invalid date, use YYYY-MM-DD")
+	}
+	return &t, nil
+}
+
+// consumeStockFEFO deducts quantity (in base units) from a product's lots, earliest
+// expiry first, and writes one StockLog per lot touched based on the entry template.
+// The template's UnitQuantity is the document quantity for the whole deduction and
+// is split across the logs. Expired lots are left for writing off rather than sold,
+// and any quantity not covered by lots is logged without a lot.
+func consumeStockFEFO(tx *gorm.DB, productID uint, quantity float64, entry models.StockLog) error {
+	if quantity <= 0 {
+		return nil
+	}
+
+	// Expiry dates are whole days, so a lot can still be sold on its expiry date
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	var lots []models.StockLot
+	if err := tx.Where("product_id = ? AND remaining_qty > 0", productID).
+		Where("expiry_date IS NULL OR expiry_date >= ?", today).
+		Order("expiry_date IS NULL, expiry_date ASC, id ASC").
+		Find(&lots).Error; err != nil {
+		return err
+	}
+
+	remaining := quantity
+	for _, lot := range lots {
+		if remaining == 0 {
+			break
+		}
+		take := lot.RemainingQty
+		if take > remaining {
+			take = remaining
+		}
+		if err := tx.Model(&lot).Update("remaining_qty", roundQty(lot.RemainingQty-take)).Error; err != nil {
+			return err
+		}
+
+		lotID := lot.ID
+		log := entry
+		log.ProductID = productID
+		log.ChangeAmount = -take
+		log.UnitQuantity = -roundQty(entry.UnitQuantity * take / quantity)
+		log.LotID = &lotID
+		if err := tx.Create(&log).Error; err != nil {
+			return err
+		}
+		remaining = roundQty(remaining - take)
+	}
+
+	if remaining > 0 {
+		log := entry
+		log.ProductID = productID
+		log.ChangeAmount = -remaining
+		log.UnitQuantity = -roundQty(entry.UnitQuantity * remaining / quantity)
+		if err := tx.Create(&log).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetStockLots - GET /api/stock/lots
+func GetStockLots(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var lots []models.StockLot
+
+		query := db.Preload("Product").Order("expiry_date IS NULL, expiry_date ASC, id ASC")
+
+		// Tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if exists && tenantID != nil {
+				query = query.Where("tenant_id = ?", *tenantID.(*uint))
+			}
+		}
+
+		// Filter by product
+		if productID := c.Query("product_id"); productID != "" {
+			query = query.Where("product_id = ?", productID)
+		}
+
+		// Hide depleted lots unless asked for
+		if c.Query("include_empty") != "true" {
+			query = query.Where("remaining_qty > 0")
+		}
+
+		if err := query.Find(&lots).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, lots)
+	}
+}
+
+// GetExpiringLots - GET /api/stock/expiring
+func GetExpiringLots(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		days := 7
+		if d := c.Query("days"); d != "" {
+			if parsed, err := strconv.Atoi(d); err == nil && parsed >= 0 {
+				days = parsed
+			}
+		}
+		cutoff := time.Now().AddDate(0, 0, days)
+
+		var lots []models.StockLot
+
+		// Already expired lots are included so they can be written off
+		query := db.Preload("Product").
+			Where("remaining_qty > 0 AND expiry_date IS NOT NULL AND expiry_date <= ?", cutoff).
+			Order("expiry_date ASC")
+
+		// Tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if exists && tenantID != nil {
+				query = query.Where("tenant_id = ?", *tenantID.(*uint))
+			}
+		}
+
+		if err := query.Find(&lots).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"days": days,
+			"lots": lots,
+		})
+	}
+}
+
+// WriteOffLotRequest - Request body for writing off a stock lot
+type WriteOffLotRequest struct {
+	Quantity float64 `json:"quantity"` // Defaults to everything left in the lot
+	Reason   string  `json:"reason"`   // Defaults to "Expired"
+}
+
+// WriteOffLot - POST /api/stock/lots/:id/write-off
+func WriteOffLot(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var req WriteOffLotRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var lot models.StockLot
+		if err := db.First(&lot, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Lot not found"})
+			return
+		}
+
+		// Check tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if !exists || tenantID == nil || lot.TenantID != *tenantID.(*uint) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+		}
+
+		quantity := req.Quantity
+		if quantity == 0 {
+			quantity = lot.RemainingQty
+		}
+		if quantity <= 0 || quantity > lot.RemainingQty {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive and no more than the lot's remaining quantity"})
+			return
+		}
+
+		reason := req.Reason
+		if reason == "" {
+			reason = "Expired"
+		}
+
+		tx := db.Begin()
+
+		lot.RemainingQty = roundQty(lot.RemainingQty - quantity)
+		if err := tx.Save(&lot).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var product models.Product
+		if err := tx.First(&product, lot.ProductID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
+		product.Stock = roundQty(product.Stock - quantity)
+		if product.Stock < 0 {
+			product.Stock = 0
+		}
+		if err := tx.Save(&product).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		lotID := lot.ID
+		log := models.StockLog{
+			TenantID:     lot.TenantID,
+			ProductID:    lot.ProductID,
+			ChangeAmount: -quantity,
+			Type:         "adjustment",
+			Reason:       reason,
+			LotID:        &lotID,
+			Unit:         baseUnit(product),
+			UnitQuantity: -quantity,
+			UserID:       c.GetUint("user_id"),
+			Username:     c.GetString("username"),
+		}
+		if err := tx.Create(&log).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx.Commit()
+		publishAvailability(db, product.TenantID, []uint{product.ID})
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Lot written off successfully",
+			"new_stock": product.Stock,
+			"lot":       lot,
+			"log":       log,
+		})
+	}
+}
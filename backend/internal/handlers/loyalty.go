@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loyaltyTier is a membership level reached by lifetime spend
+type loyaltyTier struct {
+	Name       string       `json:"name"`
+	MinSpend   money.Amount `json:"min_spend"`
+	Multiplier float64      `json:"multiplier"` // Applied to the points earned; 0 counts as 1
+}
+
+// loyaltyConfig is the points program in the tenant settings
+type loyaltyConfig struct {
+	EarnSpend  money.Amount  `json:"earn_spend"`  // Spend that earns one point; 0 turns the program off
+	PointValue money.Amount  `json:"point_value"` // What a point is worth when redeemed; 0 to only collect
+	MinRedeem  int           `json:"min_redeem"`  // Fewest points redeemable on an order
+	ExpiryDays int           `json:"expiry_days"` // Points expire this many days after they are earned; 0 for never
+	RedeemAs   string        `json:"redeem_as"`   // discount (before tax) or tender (pays part of the total)
+	Tiers      []loyaltyTier `json:"tiers"`
+}
+
+func (l loyaltyConfig) enabled() bool {
+	return l.EarnSpend > 0
+}
+
+// validate checks settings the order flow relies on
+func (l loyaltyConfig) validate() error {
+	if l.EarnSpend < 0 || l.PointValue < 0 || l.MinRedeem < 0 || l.ExpiryDays < 0 {
+		return errors.New("loyalty amounts and days cannot be negative")
+	}
+	if l.RedeemAs != "" && l.RedeemAs != "discount" && l.RedeemAs != "tender" {
+		return errors.New("loyalty redeem_as must be discount or tender")
+	}
+	for _, tier := range l.Tiers {
+		if tier.Name == "" || tier.MinSpend < 0 || tier.Multiplier < 0 {
+			return errors.New("loyalty tiers need a name, and min_spend and multiplier cannot be negative")
+		}
+	}
+	return nil
+}
+
+// tierFor returns the name of the highest tier reached with a lifetime spend
+func (l loyaltyConfig) tierFor(spend money.Amount) string {
+	tiers := append([]loyaltyTier(nil), l.Tiers...)
+	sort.SliceStable(tiers, func(a, b int) bool { return tiers[a].MinSpend < tiers[b].MinSpend })
+
+	name := ""
+	for _, tier := range tiers {
+		if spend >= tier.MinSpend {
+			name = tier.Name
+		}
+	}
+	return name
+}
+
+// pointsEarned is what paying amount earns a customer in the given tier. Part
+// points only drop once the tier multiplier has been applied.
+func (l loyaltyConfig) pointsEarned(amount money.Amount, tierName string) int {
+	if !l.enabled() || amount <= 0 {
+		return 0
+	}
+	points := float64(amount) / float64(l.EarnSpend)
+	for _, tier := range l.Tiers {
+		if tier.Name == tierName && tier.Multiplier > 0 {
+			points *= tier.Multiplier
+		}
+	}
+	return int(math.Floor(points))
+}
+
+// expiresAt is when points credited at now expire, or nil if they never do
+func (l loyaltyConfig) expiresAt(now time.Time) *time.Time {
+	if l.ExpiryDays <= 0 {
+		return nil
+	}
+	t := now.AddDate(0, 0, l.ExpiryDays)
+	return &t
+}
+
+// expiredPoints is how many of a customer's points are past their expiry but not yet written off
+func expiredPoints(db *gorm.DB, customerID uint, now time.Time) int {
+	var expired int
+	db.Model(&models.LoyaltyEntry{}).
+		Select("COALESCE(SUM(remaining), 0)").
+		Where("customer_id = ? AND remaining > 0 AND expires_at IS NOT NULL AND expires_at < ?", customerID, now).
+		Scan(&expired)
+	return expired
+}
+
+// expirePoints writes off a customer's points that are past their expiry
+func expirePoints(tx *gorm.DB, customer *models.Customer, now time.Time) error {
+	var entries []models.LoyaltyEntry
+	if err := tx.Where("customer_id = ? AND remaining > 0 AND expires_at IS NOT NULL AND expires_at < ?", customer.ID, now).
+		Find(&entries).Error; err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		expiry := models.LoyaltyEntry{
+			TenantID:   customer.TenantID,
+			CustomerID: customer.ID,
+			Type:       "expire",
+			Points:     -entry.Remaining,
+			Note:       "Points from " + entry.CreatedAt.Format("2006-01-02") + " expired",
+		}
+		if err := tx.Create(&expiry).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&entry).UpdateColumn("remaining", 0).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(customer).UpdateColumn("points", gorm.Expr("points - ?", entry.Remaining)).Error; err != nil {
+			return err
+		}
+		customer.Points -= entry.Remaining
+	}
+	return nil
+}
+
+// creditPoints adds points to a customer's balance
+func creditPoints(tx *gorm.DB, customer *models.Customer, entry models.LoyaltyEntry) error {
+	entry.TenantID = customer.TenantID
+	entry.CustomerID = customer.ID
+	entry.Remaining = entry.Points
+	if err := tx.Create(&entry).Error; err != nil {
+		return err
+	}
+	customer.Points += entry.Points
+	return tx.Model(customer).UpdateColumn("points", gorm.Expr("points + ?", entry.Points)).Error
+}
+
+// spendPoints takes points off a customer's balance, using the credits that
+// expire first. The balance is checked in the update itself, so two tills
+// cannot spend the same points.
+func spendPoints(tx *gorm.DB, customer *models.Customer, entry models.LoyaltyEntry) error {
+	points := -entry.Points
+	result := tx.Model(&models.Customer{}).
+		Where("id = ? AND points >= ?", customer.ID, points).
+		UpdateColumn("points", gorm.Expr("points - ?", points))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Not enough points")
+	}
+	customer.Points -= points
+
+	var credits []models.LoyaltyEntry
+	if err := tx.Where("customer_id = ? AND remaining > 0", customer.ID).
+		Order("expires_at IS NULL, expires_at ASC, id ASC").
+		Find(&credits).Error; err != nil {
+		return err
+	}
+	left := points
+	for _, credit := range credits {
+		if left == 0 {
+			break
+		}
+		used := credit.Remaining
+		if used > left {
+			used = left
+		}
+		if err := tx.Model(&credit).UpdateColumn("remaining", credit.Remaining-used).Error; err != nil {
+			return err
+		}
+		left -= used
+	}
+
+	entry.TenantID = customer.TenantID
+	entry.CustomerID = customer.ID
+	return tx.Create(&entry).Error
+}
+
+// updateLoyaltyTier moves a customer to the tier their lifetime spend on paid orders has reached
+func updateLoyaltyTier(tx *gorm.DB, cfg loyaltyConfig, customer *models.Customer) error {
+	if len(cfg.Tiers) == 0 {
+		return nil
+	}
+	var spend money.Amount
+	tx.Model(&models.Order{}).Select("COALESCE(SUM(total), 0)").
+		Where("customer_id = ? AND status IN ?", customer.ID, paidOrderStatuses).
+		Scan(&spend)
+
+	tier := cfg.tierFor(spend)
+	if tier == customer.LoyaltyTier {
+		return nil
+	}
+	customer.LoyaltyTier = tier
+	return tx.Model(customer).UpdateColumn("loyalty_tier", tier).Error
+}
+
+// settleLoyalty records the points an order redeemed and earned, then updates the customer's tier
+func settleLoyalty(tx *gorm.DB, quote orderQuote, orderID uint, userID uint) error {
+	customer := *quote.Customer
+	now := time.Now()
+
+	if err := expirePoints(tx, &customer, now); err != nil {
+		return err
+	}
+	if quote.PointsRedeemed > 0 {
+		if err := spendPoints(tx, &customer, models.LoyaltyEntry{
+			OrderID: &orderID,
+			Type:    "redeem",
+			Points:  -quote.PointsRedeemed,
+			Note:    "Redeemed for " + quote.PointsValue.String(),
+			UserID:  userID,
+		}); err != nil {
+			return err
+		}
+	}
+	if quote.PointsEarned > 0 {
+		if err := creditPoints(tx, &customer, models.LoyaltyEntry{
+			OrderID:   &orderID,
+			Type:      "earn",
+			Points:    quote.PointsEarned,
+			ExpiresAt: quote.Loyalty.expiresAt(now),
+			UserID:    userID,
+		}); err != nil {
+			return err
+		}
+	}
+	return updateLoyaltyTier(tx, quote.Loyalty, &customer)
+}
+
+// loadTenantCustomer fetches the customer in the URL and checks tenant access
+func loadTenantCustomer(c *gin.Context, db *gorm.DB) (models.Customer, bool) {
+	var customer models.Customer
+	if err := db.First(&customer, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		return customer, false
+	}
+
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil || customer.TenantID != *tenantID.(*uint) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return customer, false
+		}
+	}
+
+	return customer, true
+}
+
+// GetCustomerPoints - GET /api/customers/:id/points
+// Returns the balance, tier and ledger, writing off any expired points first.
+func GetCustomerPoints(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		customer, ok := loadTenantCustomer(c, db)
+		if !ok {
+			return
+		}
+
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return expirePoints(tx, &customer, time.Now())
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var entries []models.LoyaltyEntry
+		if err := db.Where("customer_id = ?", customer.ID).Order("created_at DESC, id DESC").Find(&entries).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		cfg := loadTenantConfig(db, customer.TenantID).Loyalty
+		c.JSON(http.StatusOK, gin.H{
+			"customer_id":  customer.ID,
+			"points":       customer.Points,
+			"points_value": cfg.PointValue * money.Amount(customer.Points),
+			"loyalty_tier": customer.LoyaltyTier,
+			"entries":      entries,
+		})
+	}
+}
+
+// AdjustPointsRequest - Request body for a manual points adjustment
+type AdjustPointsRequest struct {
+	Points int    `json:"points" binding:"required"` // Positive to credit, negative to deduct
+	Note   string `json:"note" binding:"required"`
+}
+
+// AdjustCustomerPoints - POST /api/customers/:id/points
+func AdjustCustomerPoints(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		if role != "owner" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can adjust points"})
+			return
+		}
+
+		var req AdjustPointsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		customer, ok := loadTenantCustomer(c, db)
+		if !ok {
+			return
+		}
+
+		cfg := loadTenantConfig(db, customer.TenantID).Loyalty
+		entry := models.LoyaltyEntry{Type: "adjust", Points: req.Points, Note: req.Note, UserID: c.GetUint("user_id")}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			now := time.Now()
+			if err := expirePoints(tx, &customer, now); err != nil {
+				return err
+			}
+			if req.Points < 0 {
+				return spendPoints(tx, &customer, entry)
+			}
+			entry.ExpiresAt = cfg.expiresAt(now)
+			return creditPoints(tx, &customer, entry)
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, customer)
+	}
+}
@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// OrderModifier - A modifier chosen for an order line. Only modifier_id is
+// needed in a request; the rest is filled in by the server.
+type OrderModifier struct {
+	ModifierID uint         `json:"modifier_id"`
+	Name       string       `json:"name"`
+	Group      string       `json:"group"`
+	Price      money.Amount `json:"price"` // Price adjustment per item
+}
+
+// productModifierGroups loads the modifier groups offered with a product, in order.
+// A variant is offered its parent's groups first, then any of its own.
+func productModifierGroups(db *gorm.DB, product models.Product) ([]models.ModifierGroup, error) {
+	productIDs := []uint{product.ID}
+	if product.ParentID != nil {
+		productIDs = []uint{*product.ParentID, product.ID}
+	}
+
+	var groups []models.ModifierGroup
+	seen := map[uint]bool{}
+	for _, productID := range productIDs {
+		var linked []models.ModifierGroup
+		err := db.Joins("JOIN product_modifier_groups ON product_modifier_groups.modifier_group_id = modifier_groups.id").
+			Where("product_modifier_groups.product_id = ?", productID).
+			Order("product_modifier_groups.sequence ASC, modifier_groups.id ASC").
+			Preload("Modifiers", func(db *gorm.DB) *gorm.DB { return db.Order("sequence ASC, id ASC") }).
+			Find(&linked).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range linked {
+			if !seen[group.ID] {
+				seen[group.ID] = true
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups, nil
+}
+
+// resolveModifiers checks the modifiers chosen for a line against the product's
+// groups and their selection rules, and fills in their names and prices
+func resolveModifiers(db *gorm.DB, product models.Product, chosen []OrderModifier) ([]OrderModifier, []models.Modifier, error) {
+	groups, err := productModifierGroups(db, product)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(groups) == 0 && len(chosen) == 0 {
+		return nil, nil, nil
+	}
+
+	options := map[uint]models.Modifier{}
+	groupNames := map[uint]string{}
+	for _, group := range groups {
+		groupNames[group.ID] = group.Name
+		for _, modifier := range group.Modifiers {
+			options[modifier.ID] = modifier
+		}
+	}
+
+	resolved := make([]OrderModifier, 0, len(chosen))
+	modifiers := make([]models.Modifier, 0, len(chosen))
+	perGroup := map[uint]int{}
+	seen := map[uint]bool{}
+	for _, choice := range chosen {
+		modifier, ok := options[choice.ModifierID]
+		if !ok {
+			return nil, nil, fmt.Errorf("Modifier %d is not offered with %s", choice.ModifierID, product.Name)
+		}
+		if seen[modifier.ID] {
+			return nil, nil, fmt.Errorf("%s is chosen twice for %s", modifier.Name, product.Name)
+		}
+		seen[modifier.ID] = true
+		perGroup[modifier.GroupID]++
+		resolved = append(resolved, OrderModifier{
+			ModifierID: modifier.ID,
+			Name:       modifier.Name,
+			Group:      groupNames[modifier.GroupID],
+			Price:      modifier.PriceAdjustment,
+		})
+		modifiers = append(modifiers, modifier)
+	}
+
+	for _, group := range groups {
+		count := perGroup[group.ID]
+		switch {
+		case count < group.MinSelect:
+			return nil, nil, fmt.Errorf("Choose at least %d %s for %s", group.MinSelect, group.Name, product.Name)
+		case group.MaxSelect > 0 && count > group.MaxSelect:
+			return nil, nil, fmt.Errorf("Choose at most %d %s for %s", group.MaxSelect, group.Name, product.Name)
+		}
+	}
+	return resolved, modifiers, nil
+}
+
+// modifierNames lists the chosen modifiers for a kitchen ticket
+func modifierNames(modifiers []OrderModifier) string {
+	names := make([]string, len(modifiers))
+	for i, m := range modifiers {
+		names[i] = m.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// ModifierRequest - One option in a modifier group request. Options are
+// matched to existing ones by id, so open orders keep referring to them.
+type ModifierRequest struct {
+	ID              uint         `json:"id"`
+	Name            string       `json:"name" binding:"required"`
+	PriceAdjustment money.Amount `json:"price_adjustment"`
+	StockProductID  *uint        `json:"stock_product_id"`
+	StockQuantity   float64      `json:"stock_quantity" binding:"gte=0"`
+}
+
+// ModifierGroupRequest - Request body for creating or updating a modifier group with its options
+type ModifierGroupRequest struct {
+	Name      string            `json:"name" binding:"required"`
+	MinSelect int               `json:"min_select" binding:"gte=0"`
+	MaxSelect int               `json:"max_select" binding:"gte=0"`
+	Modifiers []ModifierRequest `json:"modifiers" binding:"required,min=1,dive"`
+}
+
+// validate checks the selection rules and the ingredients of the options
+func (req ModifierGroupRequest) validate(db *gorm.DB, tenantID uint) error {
+	if req.MaxSelect > 0 && req.MaxSelect < req.MinSelect {
+		return errors.New("max_select cannot be less than min_select")
+	}
+	if req.MinSelect > len(req.Modifiers) {
+		return errors.New("min_select is more than the options in the group")
+	}
+	for _, m := range req.Modifiers {
+		if m.StockProductID == nil {
+			continue
+		}
+		var count int64
+		db.Model(&models.Product{}).Where("id = ? AND tenant_id = ?", *m.StockProductID, tenantID).Count(&count)
+		if count == 0 {
+			return errors.New("Ingredient of " + m.Name + " not found")
+		}
+		if m.StockQuantity <= 0 {
+			return errors.New("stock_quantity of " + m.Name + " must be positive")
+		}
+	}
+	return nil
+}
+
+// saveModifiers updates the options of a group to match the request: options
+// with an id are updated, new ones created and those left out deleted
+func saveModifiers(tx *gorm.DB, group *models.ModifierGroup, reqs []ModifierRequest) error {
+	var existing []models.Modifier
+	if err := tx.Where("group_id = ?", group.ID).Find(&existing).Error; err != nil {
+		return err
+	}
+	byID := map[uint]models.Modifier{}
+	for _, m := range existing {
+		byID[m.ID] = m
+	}
+
+	group.Modifiers = make([]models.Modifier, 0, len(reqs))
+	for i, req := range reqs {
+		modifier := models.Modifier{TenantID: group.TenantID, GroupID: group.ID}
+		if req.ID != 0 {
+			var ok bool
+			if modifier, ok = byID[req.ID]; !ok {
+				return fmt.Errorf("Modifier %d is not in this group", req.ID)
+			}
+			delete(byID, req.ID)
+		}
+		modifier.Name = req.Name
+		modifier.PriceAdjustment = req.PriceAdjustment
+		modifier.StockProductID = req.StockProductID
+		modifier.StockQuantity = req.StockQuantity
+		modifier.Sequence = i + 1
+		if err := tx.Save(&modifier).Error; err != nil {
+			return err
+		}
+		group.Modifiers = append(group.Modifiers, modifier)
+	}
+
+	for _, removed := range byID {
+		if err := tx.Delete(&removed).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTenantModifierGroup fetches the modifier group in the URL and checks tenant access
+func loadTenantModifierGroup(c *gin.Context, db *gorm.DB) (models.ModifierGroup, bool) {
+	var group models.ModifierGroup
+	if err := db.First(&group, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Modifier group not found"})
+		return group, false
+	}
+
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil || group.TenantID != *tenantID.(*uint) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return group, false
+		}
+	}
+
+	return group, true
+}
+
+// GetModifierGroups - GET /api/modifier-groups
+func GetModifierGroups(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var groups []models.ModifierGroup
+
+		query := db.Order("name ASC").Preload("Modifiers", func(db *gorm.DB) *gorm.DB { return db.Order("sequence ASC, id ASC") })
+
+		// Tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if exists && tenantID != nil {
+				query = query.Where("tenant_id = ?", *tenantID.(*uint))
+			}
+		}
+
+		if err := query.Find(&groups).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, groups)
+	}
+}
+
+// CreateModifierGroup - POST /api/modifier-groups
+func CreateModifierGroup(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ModifierGroupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+		if err := req.validate(db, *tenantID.(*uint)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		group := models.ModifierGroup{TenantID: *tenantID.(*uint), Name: req.Name, MinSelect: req.MinSelect, MaxSelect: req.MaxSelect}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&group).Error; err != nil {
+				return err
+			}
+			return saveModifiers(tx, &group, req.Modifiers)
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, group)
+	}
+}
+
+// UpdateModifierGroup - PUT /api/modifier-groups/:id
+func UpdateModifierGroup(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, ok := loadTenantModifierGroup(c, db)
+		if !ok {
+			return
+		}
+
+		var req ModifierGroupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := req.validate(db, group.TenantID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		group.Name = req.Name
+		group.MinSelect = req.MinSelect
+		group.MaxSelect = req.MaxSelect
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&group).Error; err != nil {
+				return err
+			}
+			return saveModifiers(tx, &group, req.Modifiers)
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+// DeleteModifierGroup - DELETE /api/modifier-groups/:id
+// The group is also taken off the products it was offered with.
+func DeleteModifierGroup(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, ok := loadTenantModifierGroup(c, db)
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("modifier_group_id = ?", group.ID).Delete(&models.ProductModifierGroup{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("group_id = ?", group.ID).Delete(&models.Modifier{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&group).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Modifier group deleted"})
+	}
+}
+
+// GetProductModifierGroups - GET /api/products/:id/modifier-groups
+func GetProductModifierGroups(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		groups, err := productModifierGroups(db, product)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, groups)
+	}
+}
+
+// SetProductModifierGroups - PUT /api/products/:id/modifier-groups
+// Replaces the groups offered with the product, in the order given.
+func SetProductModifierGroups(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			ModifierGroupIDs []uint `json:"modifier_group_ids" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("product_id = ?", product.ID).Delete(&models.ProductModifierGroup{}).Error; err != nil {
+				return err
+			}
+			for i, id := range req.ModifierGroupIDs {
+				var count int64
+				tx.Model(&models.ModifierGroup{}).Where("id = ? AND tenant_id = ?", id, product.TenantID).Count(&count)
+				if count == 0 {
+					return fmt.Errorf("Modifier group %d not found", id)
+				}
+				link := models.ProductModifierGroup{ProductID: product.ID, ModifierGroupID: id, Sequence: i + 1}
+				if err := tx.Create(&link).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		groups, _ := productModifierGroups(db, product)
+		c.JSON(http.StatusOK, groups)
+	}
+}
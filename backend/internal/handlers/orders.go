@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -71,92 +74,501 @@ func GetOrder(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// CreateOrderRequest - Request body for creating or previewing an order
+type CreateOrderRequest struct {
+	TenantID      uint               `json:"tenant_id"` // Only used by superadmin
+	Items         []OrderItemRequest `json:"items" binding:"required,min=1"`
+	Discount      money.Amount       `json:"discount"` // Manual discount on top of promotions
+	VoucherCode   string             `json:"voucher_code,omitempty"`
+	RedeemPoints  int                `json:"redeem_points,omitempty" binding:"gte=0"` // Loyalty points to redeem; needs customer_id
+	PaymentMethod string             `json:"payment_method"`
+	Payments      []PaymentRequest   `json:"payments,omitempty" binding:"dive"` // Split tenders; replaces payment_method
+	TableID       *uint              `json:"table_id,omitempty"`
+	TableNumber   string             `json:"table_number,omitempty"` // Taken from the table when table_id is set
+	CustomerID    *uint              `json:"customer_id,omitempty"`
+	CustomerName  string             `json:"customer_name,omitempty"`
+	CustomerPhone string             `json:"customer_phone,omitempty"`
+	CustomerGroup string             `json:"customer_group,omitempty"` // Overrides the customer's group, e.g. wholesale mode
+	Outlet        string             `json:"outlet,omitempty"`
+	Open          bool               `json:"open,omitempty"` // Start an open tab at table_id, paid later
+}
+
+// orderQuote is an order request priced and discounted by the server
+type orderQuote struct {
+	TenantID    uint
+	Pricing     priceContext
+	Table       *models.Table
+	Items       []pricedItem
+	Subtotal    money.Amount
+	Discounts   []DiscountLine
+	Discount    money.Amount
+	Taxes       []TaxLine
+	Tax         money.Amount // All taxes, including those already in the prices
+	TaxIncluded money.Amount
+	Rounding    money.Amount // Cash rounding added to the total, may be negative
+	Total       money.Amount
+	AmountDue   money.Amount // Total less what points paid as a tender
+	Currency    string
+
+	Voucher         *models.Voucher
+	VoucherDiscount money.Amount
+
+	Customer       *models.Customer
+	Loyalty        loyaltyConfig
+	PointsRedeemed int
+	PointsValue    money.Amount // What the redeemed points are worth
+	PointsPaid     money.Amount // Part of PointsValue used as a tender rather than a discount
+	PointsEarned   int
+
+	Payments  []PaymentRequest
+	Change    money.Amount // Cash tendered over the amount due
+	OnAccount money.Amount // Charged to the customer's account
+	GiftCards []giftCardTender
+}
+
+// lines returns the quoted order lines as stored in the order details
+func (q orderQuote) lines() []OrderItemRequest {
+	lines := make([]OrderItemRequest, len(q.Items))
+	for i, item := range q.Items {
+		lines[i] = item.OrderItemRequest
+	}
+	return lines
+}
+
+// quoteOrder resolves the customer, prices the items and applies promotions,
+// vouchers, loyalty points and the manual discount, then rounds cash totals for
+// the tenant's currency. Errors are the client's fault and meant for a 400.
+func quoteOrder(c *gin.Context, db *gorm.DB, orderReq *CreateOrderRequest) (orderQuote, error) {
+	// Orders belong to the cashier's tenant
+	quote := orderQuote{TenantID: orderReq.TenantID}
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tid, exists := c.Get("tenant_id")
+		if exists && tid != nil {
+			quote.TenantID = *tid.(*uint)
+		}
+	}
+
+	cfg := loadTenantConfig(db, quote.TenantID)
+	quote.Loyalty = cfg.Loyalty
+	currency := cfg.currency()
+
+	quote.Pricing = priceContext{CustomerGroup: "retail", Outlet: orderReq.Outlet}
+	if orderReq.CustomerID != nil {
+		var customer models.Customer
+		if err := db.Where("id = ? AND tenant_id = ?", *orderReq.CustomerID, quote.TenantID).First(&customer).Error; err != nil {
+			return quote, errors.New("Customer not found")
+		}
+		quote.Customer = &customer
+		if customer.CustomerGroup != "" {
+			quote.Pricing.CustomerGroup = customer.CustomerGroup
+		}
+		if orderReq.CustomerName == "" {
+			orderReq.CustomerName = customer.Name
+		}
+		if orderReq.CustomerPhone == "" {
+			orderReq.CustomerPhone = customer.Phone
+		}
+	}
+	if orderReq.TableID != nil {
+		var table models.Table
+		if err := db.Where("id = ? AND tenant_id = ?", *orderReq.TableID, quote.TenantID).First(&table).Error; err != nil {
+			return quote, errors.New("Table not found")
+		}
+		quote.Table = &table
+		orderReq.TableNumber = table.Name
+	}
+	if orderReq.CustomerGroup != "" {
+		if !customerGroups[orderReq.CustomerGroup] {
+			return quote, errors.New("customer_group must be retail, wholesale or member")
+		}
+		quote.Pricing.CustomerGroup = orderReq.CustomerGroup
+	}
+
+	items, subtotal, err := priceOrderItems(db, quote.TenantID, orderReq.Items, quote.Pricing, currency.Unit())
+	if err != nil {
+		return quote, err
+	}
+	quote.Items = items
+	quote.Subtotal = subtotal
+
+	// Happy hours and weekdays are in the tenant's time zone
+	now := time.Now()
+	quote.Discounts = applyPromotions(db, quote.TenantID, items, subtotal, now.In(cfg.location()), currency.Unit())
+
+	// Vouchers apply to what is left after promotions
+	if orderReq.VoucherCode != "" {
+		spend := subtotal
+		for _, d := range quote.Discounts {
+			spend -= d.Amount
+		}
+		voucher, err := checkVoucher(db, quote.TenantID, orderReq.VoucherCode, orderReq.CustomerID, spend, now)
+		if err != nil {
+			return quote, err
+		}
+		if line := applyOrderDiscount(items, voucherDiscount(voucher, spend, currency.Unit()), "Voucher "+voucher.Code, voucherReason(voucher)); line != nil {
+			line.VoucherID = &voucher.ID
+			quote.Discounts = append(quote.Discounts, *line)
+			quote.Voucher = &voucher
+			quote.VoucherDiscount = line.Amount
+		}
+	}
+
+	// Points are worth PointValue each, taken off before tax or paid as a tender after it
+	if orderReq.RedeemPoints > 0 {
+		if quote.Customer == nil {
+			return quote, errors.New("Select a customer to redeem points")
+		}
+		if !quote.Loyalty.enabled() || quote.Loyalty.PointValue <= 0 {
+			return quote, errors.New("Points cannot be redeemed")
+		}
+		if orderReq.RedeemPoints < quote.Loyalty.MinRedeem {
+			return quote, fmt.Errorf("Redeem at least %d points", quote.Loyalty.MinRedeem)
+		}
+		if available := quote.Customer.Points - expiredPoints(db, quote.Customer.ID, now); orderReq.RedeemPoints > available {
+			return quote, fmt.Errorf("Customer has only %d points", available)
+		}
+		quote.PointsRedeemed = orderReq.RedeemPoints
+		quote.PointsValue = quote.Loyalty.PointValue * money.Amount(orderReq.RedeemPoints)
+
+		if quote.Loyalty.RedeemAs != "tender" {
+			left := subtotal
+			for _, d := range quote.Discounts {
+				left -= d.Amount
+			}
+			if quote.PointsValue > left {
+				return quote, errors.New("Points are worth more than the order")
+			}
+			reason := fmt.Sprintf("%d points", orderReq.RedeemPoints)
+			if line := applyOrderDiscount(items, quote.PointsValue, "Loyalty points", reason); line != nil {
+				quote.Discounts = append(quote.Discounts, *line)
+			}
+		}
+	}
+
+	if orderReq.Discount > 0 {
+		if manual := applyOrderDiscount(items, orderReq.Discount, "Manual discount", "Applied by cashier"); manual != nil {
+			quote.Discounts = append(quote.Discounts, *manual)
+		}
+	}
+	for _, d := range quote.Discounts {
+		quote.Discount += d.Amount
+	}
+
+	// Taxes are charged on the discounted lines; inclusive ones are already in the prices
+	var added money.Amount
+	quote.Taxes, quote.TaxIncluded, added = applyTaxes(db, quote.TenantID, items, currency.Unit())
+	quote.Tax = quote.TaxIncluded + added
+	quote.Total = quote.Subtotal - quote.Discount + added
+
+	quote.AmountDue = quote.Total
+	if quote.PointsValue > 0 && quote.Loyalty.RedeemAs == "tender" {
+		if quote.PointsValue > quote.Total {
+			return quote, errors.New("Points are worth more than the order")
+		}
+		quote.PointsPaid = quote.PointsValue
+		quote.AmountDue = quote.Total - quote.PointsPaid
+	}
+
+	// Cash cannot be paid in the smallest coins, so what is left to pay in cash is rounded
+	quote.Currency = currency.Code
+	if paysCash(orderReq) {
+		rounded := quote.AmountDue.RoundTo(currency.CashRounding)
+		quote.Rounding = rounded - quote.AmountDue
+		quote.AmountDue = rounded
+		quote.Total += quote.Rounding
+	}
+
+	// Points are earned on what the customer pays, not on what points paid
+	if quote.Customer != nil {
+		quote.PointsEarned = quote.Loyalty.pointsEarned(quote.AmountDue, quote.Customer.LoyaltyTier)
+	}
+	// An open tab is paid when it is settled
+	if orderReq.Open {
+		return quote, nil
+	}
+	return quote, quotePayments(db, &quote, orderReq)
+}
+
+// PreviewOrder - POST /api/orders/preview
+// Prices an order without saving it, listing each discount and its reason.
+func PreviewOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var orderReq CreateOrderRequest
+		if err := c.ShouldBindJSON(&orderReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		quote, err := quoteOrder(c, db, &orderReq)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"items":        quote.lines(),
+			"subtotal":     quote.Subtotal,
+			"discounts":    quote.Discounts,
+			"discount":     quote.Discount,
+			"taxes":        quote.Taxes,
+			"tax":          quote.Tax,
+			"tax_included": quote.TaxIncluded,
+			"rounding":     quote.Rounding,
+			"total":        quote.Total,
+			"points_paid":  quote.PointsPaid,
+			"amount_due":   quote.AmountDue,
+			"payments":     quote.Payments,
+			"change":       quote.Change,
+			"currency":     quote.Currency,
+			"points": gin.H{
+				"redeemed": quote.PointsRedeemed,
+				"value":    quote.PointsValue,
+				"earned":   quote.PointsEarned,
+			},
+		})
+	}
+}
+
+// orderDetails builds the details JSON stored with an order
+func orderDetails(quote orderQuote, orderReq CreateOrderRequest) map[string]interface{} {
+	return map[string]interface{}{
+		"items":           quote.lines(),
+		"subtotal":        quote.Subtotal,
+		"discounts":       quote.Discounts,
+		"taxes":           quote.Taxes,
+		"tax":             quote.Tax,
+		"tax_included":    quote.TaxIncluded,
+		"discount":        quote.Discount,
+		"rounding":        quote.Rounding,
+		"currency":        quote.Currency,
+		"points_paid":     quote.PointsPaid,
+		"amount_due":      quote.AmountDue,
+		"points_redeemed": quote.PointsRedeemed,
+		"points_earned":   quote.PointsEarned,
+		"payment_method":  orderReq.PaymentMethod,
+		"payments":        quote.Payments,
+		"change":          quote.Change,
+		"table_id":        orderReq.TableID,
+		"table_number":    orderReq.TableNumber,
+		"customer_id":     orderReq.CustomerID,
+		"customer_name":   orderReq.CustomerName,
+		"customer_phone":  orderReq.CustomerPhone,
+		"customer_group":  quote.Pricing.CustomerGroup,
+		"outlet":          quote.Pricing.Outlet,
+		"created_at":      time.Now().Format(time.RFC3339),
+	}
+}
+
+// completeOrder uses up what a paid order redeemed and tendered, awards its
+// points and deducts its stock, inside the order transaction. The status code
+// says whose fault an error is.
+func completeOrder(c *gin.Context, tx *gorm.DB, quote orderQuote, order models.Order) (int, error) {
+	if quote.Voucher != nil {
+		if err := redeemVoucher(tx, *quote.Voucher, order.ID, order.CustomerID, quote.VoucherDiscount); err != nil {
+			return http.StatusConflict, err
+		}
+	}
+
+	if err := settlePayments(tx, quote, order.ID, c.GetUint("user_id")); err != nil {
+		return http.StatusConflict, err
+	}
+
+	if quote.Customer != nil && quote.Loyalty.enabled() {
+		if err := settleLoyalty(tx, quote, order.ID, c.GetUint("user_id")); err != nil {
+			return http.StatusConflict, err
+		}
+	}
+
+	// Update stock for each item, consuming lots first-expired-first-out
+	for _, item := range quote.Items {
+		recipe, err := productRecipe(tx, item.Product.ID)
+		if err != nil {
+			return http.StatusInternalServerError, errors.New("Failed to update stock")
+		}
+
+		// Items sold in a pack unit (e.g. "crate") are deducted in base units
+		if len(recipe) == 0 {
+			if err := sellStock(c, tx, order.ID, item.Product.ID, item.Quantity*item.Unit.Factor, "Sold", item.Unit); err != nil {
+				return http.StatusInternalServerError, err
+			}
+		}
+
+		// Items made to a recipe use up their ingredients instead
+		for _, line := range recipe {
+			quantity := line.Quantity * item.Quantity * item.Unit.Factor
+			if err := sellStock(c, tx, order.ID, line.IngredientID, quantity, "Recipe: "+item.Name, models.ProductUnit{}); err != nil {
+				return http.StatusInternalServerError, err
+			}
+		}
+
+		// Modifiers with an ingredient use it up for every base unit sold
+		for _, option := range item.Options {
+			if option.StockProductID == nil {
+				continue
+			}
+			quantity := option.StockQuantity * item.Quantity * item.Unit.Factor
+			if err := sellStock(c, tx, order.ID, *option.StockProductID, quantity, "Modifier: "+option.Name, models.ProductUnit{}); err != nil {
+				return http.StatusInternalServerError, err
+			}
+		}
+	}
+	return 0, nil
+}
+
+// sellStock deducts quantity base units of a sold product, no further than its
+// stock on hand. The unit is the one the product was sold in; the base unit when
+// it is left empty.
+func sellStock(c *gin.Context, tx *gorm.DB, orderID, productID uint, quantity float64, reason string, unit models.ProductUnit) error {
+	var product models.Product
+	if err := tx.First(&product, productID).Error; err != nil {
+		return errors.New("Failed to update stock")
+	}
+
+	deducted := roundQty(quantity)
+	if deducted > product.Stock {
+		deducted = product.Stock
+	}
+	product.Stock = roundQty(product.Stock - deducted)
+	tx.Save(&product)
+
+	if unit.Factor == 0 {
+		unit = models.ProductUnit{Name: product.Unit, Factor: 1}
+	}
+	entry := models.StockLog{
+		TenantID:     product.TenantID,
+		Type:         "sale",
+		Reason:       reason,
+		ReferenceID:  &orderID,
+		Unit:         unit.Name,
+		UnitQuantity: deducted / unit.Factor,
+		UserID:       c.GetUint("user_id"),
+		Username:     c.GetString("username"),
+	}
+	if err := consumeStockFEFO(tx, product.ID, deducted, entry); err != nil {
+		return errors.New("Failed to update stock")
+	}
+
+	// What was sold beyond the stock on hand is logged too, so usage reports
+	// still count it as sold
+	if shortfall := roundQty(quantity - deducted); shortfall > 0 {
+		entry.ProductID = product.ID
+		entry.UnitQuantity = 0
+		entry.Shortfall = shortfall
+		if err := tx.Create(&entry).Error; err != nil {
+			return errors.New("Failed to update stock")
+		}
+	}
+	return nil
+}
+
+// orderResponse is what CreateOrder and SettleOrder return for a paid order
+func orderResponse(order models.Order, quote orderQuote, message string) gin.H {
+	return gin.H{
+		"order_id":     order.ID,
+		"order_number": order.ID,
+		"status":       order.Status,
+		"subtotal":     quote.Subtotal,
+		"discount":     quote.Discount,
+		"tax":          quote.Tax,
+		"rounding":     quote.Rounding,
+		"total":        order.Total,
+		"points_paid":  quote.PointsPaid,
+		"amount_due":   quote.AmountDue,
+		"payments":     quote.Payments,
+		"change":       quote.Change,
+		"currency":     quote.Currency,
+		"items":        quote.lines(),
+		"message":      message,
+		"points": gin.H{
+			"redeemed": quote.PointsRedeemed,
+			"earned":   quote.PointsEarned,
+		},
+	}
+}
+
 // CreateOrder - POST /api/orders
+// Prices, promotions and totals are worked out on the server, as in PreviewOrder.
+// With open set, a tab is started at a table instead and paid later with SettleOrder.
 func CreateOrder(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var orderReq struct {
-			TenantID      uint                     `json:"tenant_id"`
-			Items         []map[string]interface{} `json:"items"`
-			Subtotal      float64                  `json:"subtotal"`
-			Tax           float64                  `json:"tax"`
-			Discount      float64                  `json:"discount"`
-			Total         float64                  `json:"total"`
-			PaymentMethod string                   `json:"payment_method"`
-			TableNumber   string                   `json:"table_number,omitempty"`
-			CustomerName  string                   `json:"customer_name,omitempty"`
-			CustomerPhone string                   `json:"customer_phone,omitempty"`
-		}
-		
+		var orderReq CreateOrderRequest
 		if err := c.ShouldBindJSON(&orderReq); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		
-		// Build order details JSON
-		detailsMap := map[string]interface{}{
-			"items":          orderReq.Items,
-			"subtotal":       orderReq.Subtotal,
-			"tax":            orderReq.Tax,
-			"discount":       orderReq.Discount,
-			"payment_method": orderReq.PaymentMethod,
-			"table_number":   orderReq.TableNumber,
-			"customer_name":  orderReq.CustomerName,
-			"customer_phone": orderReq.CustomerPhone,
-			"created_at":     time.Now().Format(time.RFC3339),
-		}
-		
-		detailsJSON, _ := json.Marshal(detailsMap)
-		
-		order := models.Order{
-			TenantID: orderReq.TenantID,
-			Status:   "PAID",
-			Total:    orderReq.Total,
-			Details:  string(detailsJSON),
+		if orderReq.Open {
+			openTab(c, db, &orderReq)
+			return
 		}
-		
+
 		// Transaction: Create order and update stock
 		tx := db.Begin()
-		
+
+		quote, err := quoteOrder(c, tx, &orderReq)
+		if err == nil {
+			err = checkSellable(tx, quote.TenantID, quote.Items)
+		}
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		detailsJSON, _ := json.Marshal(orderDetails(quote, orderReq))
+
+		order := models.Order{
+			TenantID:   quote.TenantID,
+			CustomerID: orderReq.CustomerID,
+			TableID:    orderReq.TableID,
+			Status:     "PAID",
+			Total:      quote.Total,
+			Tax:        quote.Tax,
+			Details:    string(detailsJSON),
+		}
+
 		if err := tx.Create(&order).Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
 			return
 		}
-		
-		// Update stock for each item
-		for _, item := range orderReq.Items {
-			productID, ok := item["product_id"].(float64)
-			quantity, qok := item["quantity"].(float64)
-			
-			if ok && qok {
-				var product models.Product
-				if err := tx.First(&product, uint(productID)).Error; err == nil {
-					product.Stock -= int(quantity)
-					if product.Stock < 0 {
-						product.Stock = 0
-					}
-					tx.Save(&product)
-				}
-			}
+
+		if status, err := completeOrder(c, tx, quote, order); err != nil {
+			tx.Rollback()
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
 		}
-		
+
+		tickets, err := createKitchenTickets(tx, order, orderReq.TableNumber, 1, quote.Items)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
 		tx.Commit()
-		
-		c.JSON(http.StatusCreated, gin.H{
-			"order_id":     order.ID,
-			"order_number": order.ID,
-			"status":       order.Status,
-			"total":        order.Total,
-			"message":      "Order created successfully",
-		})
+		publishTickets(tickets)
+		publishStockOuts(db, quote)
+
+		c.JSON(http.StatusCreated, orderResponse(order, quote, "Order created successfully"))
 	}
 }
 
+// servedStatuses are what a paid order can be marked as once it is handed over
+var servedStatuses = map[string]bool{"SERVED": true, "COMPLETED": true}
+
+// paidOrderStatuses are the statuses of an order that was paid for, the ones that
+// count towards what a customer spent. Open tabs, merged and cancelled bills do not.
+var paidOrderStatuses = []string{"PAID", "SERVED", "COMPLETED"}
+
+// isPaidOrder reports whether an order was paid for
+func isPaidOrder(order models.Order) bool {
+	return order.Status == "PAID" || servedStatuses[order.Status]
+}
+
 // UpdateOrderStatus - PATCH /api/orders/:id/status
 func UpdateOrderStatus(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		
 		var statusUpdate struct {
 			Status string `json:"status"`
 		}
@@ -166,34 +578,60 @@ func UpdateOrderStatus(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 		
-		var order models.Order
-		if err := db.First(&order, id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		order, ok := loadTenantOrder(c, db)
+		if !ok {
 			return
 		}
 		
+		// Tabs are opened, merged, settled and cancelled through their own endpoints,
+		// which take payment and stock; here a paid order is only marked as handed over
+		if !servedStatuses[statusUpdate.Status] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be SERVED or COMPLETED"})
+			return
+		}
+		if !isPaidOrder(order) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Order is " + order.Status})
+			return
+		}
+		
+		result := db.Model(&order).Where("status = ?", order.Status).Update("status", statusUpdate.Status)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Order has changed; reload it"})
+			return
+		}
 		order.Status = statusUpdate.Status
-		db.Save(&order)
 		
 		c.JSON(http.StatusOK, order)
 	}
 }
 
 // GetDailySales - GET /api/orders/daily-sales
+// Gift cards sold are owed to their holders and are reported apart from sales;
+// orders paid with a gift card count as sales when the card is spent.
 func GetDailySales(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tenantID := c.Query("tenant_id")
 		date := c.DefaultQuery("date", time.Now().Format("2006-01-02"))
 		
 		var result struct {
-			TotalSales   float64 `json:"total_sales"`
-			OrderCount   int64   `json:"order_count"`
-			AverageOrder float64 `json:"average_order"`
+			TotalSales   money.Amount `json:"total_sales"`
+			OrderCount   int64        `json:"order_count"`
+			AverageOrder money.Amount `json:"average_order"`
+
+			GiftCardsSold     money.Amount `json:"gift_cards_sold"`     // Issued and topped up on the day
+			GiftCardsRedeemed money.Amount `json:"gift_cards_redeemed"` // Spent on the day
+			GiftCardLiability money.Amount `json:"gift_card_liability"` // Balance left on active cards
+
+			WorkOrderDeposits money.Amount `json:"work_order_deposits"` // Taken at intake on the day, applied when picked up
 		}
 		
 		query := db.Model(&models.Order{}).
 			Where("DATE(created_at) = ?", date).
-			Where("status = ?", "PAID")
+			Where("status IN ?", paidOrderStatuses)
 		
 		if tenantID != "" {
 			query = query.Where("tenant_id = ?", tenantID)
@@ -203,8 +641,31 @@ func GetDailySales(db *gorm.DB) gin.HandlerFunc {
 			Scan(&result)
 		
 		if result.OrderCount > 0 {
-			result.AverageOrder = result.TotalSales / float64(result.OrderCount)
+			result.AverageOrder = money.FromFloat(result.TotalSales.Float() / float64(result.OrderCount))
+		}
+
+		entries := db.Model(&models.GiftCardEntry{}).Select("COALESCE(SUM(amount), 0)").Where("DATE(created_at) = ?", date)
+		cards := db.Model(&models.GiftCard{}).Select("COALESCE(SUM(balance), 0)").Where("status = ?", "active")
+		deposits := db.Model(&models.WorkOrder{}).Select("COALESCE(SUM(deposit), 0)").Where("DATE(deposit_paid_at) = ?", date)
+		// Tenant isolation; only superadmins pick the tenant
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tid, exists := c.Get("tenant_id")
+			if exists && tid != nil {
+				entries = entries.Where("tenant_id = ?", *tid.(*uint))
+				cards = cards.Where("tenant_id = ?", *tid.(*uint))
+				deposits = deposits.Where("tenant_id = ?", *tid.(*uint))
+			}
+		} else if tenantID != "" {
+			entries = entries.Where("tenant_id = ?", tenantID)
+			cards = cards.Where("tenant_id = ?", tenantID)
+			deposits = deposits.Where("tenant_id = ?", tenantID)
 		}
+		entries.Session(&gorm.Session{}).Where("type IN ?", []string{"issue", "top_up"}).Scan(&result.GiftCardsSold)
+		entries.Session(&gorm.Session{}).Where("type = ?", "redeem").Scan(&result.GiftCardsRedeemed)
+		result.GiftCardsRedeemed = -result.GiftCardsRedeemed
+		cards.Scan(&result.GiftCardLiability)
+		deposits.Scan(&result.WorkOrderDeposits)
 		
 		c.JSON(http.StatusOK, result)
 	}
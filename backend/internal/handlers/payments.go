@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// tenderOnAccount is the payment method that charges an order to the customer's account
+const tenderOnAccount = "on_account"
+
+// PaymentRequest - One tender of an order paid in several ways
+type PaymentRequest struct {
+	Method string       `json:"method" binding:"required"` // cash, card, qris, on_account, gift_card, ...
+	Amount money.Amount `json:"amount" binding:"gt=0"`
+	Code   string       `json:"code,omitempty"` // Gift card code of a gift_card tender
+}
+
+// giftCardTender is what an order spends from one gift card
+type giftCardTender struct {
+	Card   models.GiftCard
+	Amount money.Amount
+}
+
+// paysCash reports whether any cash is tendered, in which case the amount due is cash rounded
+func paysCash(orderReq *CreateOrderRequest) bool {
+	if len(orderReq.Payments) == 0 {
+		return strings.EqualFold(orderReq.PaymentMethod, "cash")
+	}
+	for _, p := range orderReq.Payments {
+		if strings.EqualFold(p.Method, "cash") {
+			return true
+		}
+	}
+	return false
+}
+
+// quotePayments checks the tenders against the amount due. Without payments the
+// whole amount is paid with payment_method. Only cash can be overpaid, the
+// excess being the change.
+func quotePayments(db *gorm.DB, quote *orderQuote, orderReq *CreateOrderRequest) error {
+	payments := orderReq.Payments
+	if len(payments) == 0 {
+		payments = []PaymentRequest{{Method: orderReq.PaymentMethod, Amount: quote.AmountDue}}
+	}
+
+	var paid, cash, onAccount money.Amount
+	var giftCards []giftCardTender
+	for _, p := range payments {
+		paid += p.Amount
+		switch {
+		case strings.EqualFold(p.Method, "cash"):
+			cash += p.Amount
+		case p.Method == tenderOnAccount:
+			onAccount += p.Amount
+		case p.Method == tenderGiftCard:
+			card, err := checkGiftCard(db, quote.TenantID, p.Code, time.Now())
+			if err != nil {
+				return err
+			}
+			// The same card may be tendered more than once
+			found := false
+			for i := range giftCards {
+				if giftCards[i].Card.ID == card.ID {
+					giftCards[i].Amount += p.Amount
+					found = true
+				}
+			}
+			if !found {
+				giftCards = append(giftCards, giftCardTender{Card: card, Amount: p.Amount})
+			}
+		}
+	}
+	for _, t := range giftCards {
+		if t.Amount > t.Card.Balance {
+			return fmt.Errorf("Gift card %s has only %s left", t.Card.Code, t.Card.Balance.String())
+		}
+	}
+	if paid < quote.AmountDue {
+		return fmt.Errorf("Payments are %s short of the amount due", (quote.AmountDue - paid).String())
+	}
+	quote.Change = paid - quote.AmountDue
+	if quote.Change > cash {
+		return errors.New("Only cash can be paid over the amount due")
+	}
+
+	if onAccount > 0 {
+		if quote.Customer == nil {
+			return errors.New("Select a customer to pay on account")
+		}
+		if available := quote.Customer.CreditLimit - quote.Customer.Balance; onAccount > available {
+			return fmt.Errorf("Credit limit exceeded, %s available", max(available, 0).String())
+		}
+	}
+	quote.Payments = payments
+	quote.OnAccount = onAccount
+	quote.GiftCards = giftCards
+	return nil
+}
+
+// settlePayments records the tenders that move a balance: gift cards spent and
+// charges to the customer's account
+func settlePayments(tx *gorm.DB, quote orderQuote, orderID uint, userID uint) error {
+	note := fmt.Sprintf("Order #%d", orderID)
+	for _, t := range quote.GiftCards {
+		card := t.Card
+		if err := moveGiftCard(tx, &card, models.GiftCardEntry{
+			OrderID: &orderID,
+			Type:    "redeem",
+			Amount:  -t.Amount,
+			Note:    note,
+			UserID:  userID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if quote.OnAccount == 0 {
+		return nil
+	}
+	customer := *quote.Customer
+	return chargeAccount(tx, &customer, models.ReceivableEntry{
+		OrderID: &orderID,
+		Amount:  quote.OnAccount,
+		Note:    note,
+		UserID:  userID,
+	})
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// customerGroups are the groups a price tier or customer can belong to
+var customerGroups = map[string]bool{"retail": true, "wholesale": true, "member": true}
+
+// priceContext is who is buying and where, which decides the price tiers that apply
+type priceContext struct {
+	CustomerGroup string
+	Outlet        string
+}
+
+// resolvePrice returns a product's base unit price for quantity base units and the
+// tier that set it, or the product's own price and nil when no tier applies.
+// Once a tier for the outlet applies it overrides the general tiers; otherwise the
+// buyer gets the lowest price among the tiers for everyone and their group.
+func resolvePrice(db *gorm.DB, product models.Product, quantity float64, pc priceContext) (money.Amount, *models.PriceTier) {
+	var tiers []models.PriceTier
+	db.Where("product_id = ? AND min_qty <= ?", product.ID, quantity).
+		Where("customer_group = '' OR customer_group = ?", pc.CustomerGroup).
+		Where("outlet = '' OR outlet = ?", pc.Outlet).
+		Find(&tiers)
+
+	hasOutletTiers := false
+	for _, tier := range tiers {
+		if tier.Outlet != "" {
+			hasOutletTiers = true
+		}
+	}
+
+	var best *models.PriceTier
+	for i := range tiers {
+		tier := &tiers[i]
+		if hasOutletTiers && tier.Outlet == "" {
+			continue
+		}
+		if best == nil || tier.Price < best.Price {
+			best = tier
+		}
+	}
+
+	if best == nil {
+		return product.Price, nil
+	}
+	return best.Price, best
+}
+
+// OrderItemRequest - A line of an order request. Price and Subtotal are set by the server.
+type OrderItemRequest struct {
+	ProductID   uint            `json:"product_id"`
+	VariantID   uint            `json:"variant_id,omitempty"` // Set when selling a variant; takes precedence over product_id
+	Name        string          `json:"name"`
+	Quantity    float64         `json:"quantity"`
+	Unit        string          `json:"unit,omitempty"` // Defaults to the product's base unit
+	Price       money.Amount    `json:"price"`          // Price per Unit
+	Subtotal    money.Amount    `json:"subtotal"`       // Price × Quantity, before discounts
+	Discount    money.Amount    `json:"discount"`       // Share of the order's discounts
+	Tax         money.Amount    `json:"tax"`            // Taxes and charges on the discounted line
+	PriceTierID *uint           `json:"price_tier_id,omitempty"`
+	LineID      int             `json:"line_id,omitempty"`   // Line on an open tab, for voids
+	Round       int             `json:"round,omitempty"`     // Round of an open tab the line was ordered in
+	Note        string          `json:"note,omitempty"`      // For the kitchen, e.g. "no sugar"
+	Seat        int             `json:"seat,omitempty"`      // Seat at the table, for splitting the bill by seat
+	Modifiers   []OrderModifier `json:"modifiers,omitempty"` // Chosen options, priced into Price
+}
+
+// pricedItem is an order line with its product and unit loaded
+type pricedItem struct {
+	OrderItemRequest
+	Product models.Product
+	Unit    models.ProductUnit
+	Options []models.Modifier // The chosen modifiers, for their ingredients
+}
+
+// priceOrderItems loads the products of a tenant's order lines and prices each line.
+// Quantity breaks count every line of the same product in the order together, and
+// line subtotals are rounded to the currency unit.
+func priceOrderItems(db *gorm.DB, tenantID uint, items []OrderItemRequest, pc priceContext, currencyUnit money.Amount) ([]pricedItem, money.Amount, error) {
+	priced := make([]pricedItem, 0, len(items))
+	totals := map[uint]float64{}
+
+	for _, item := range items {
+		productID := item.ProductID
+		if item.VariantID != 0 {
+			productID = item.VariantID
+		}
+		if item.Quantity <= 0 {
+			return nil, 0, errors.New("Quantity must be positive")
+		}
+
+		var product models.Product
+		if err := db.Where("id = ? AND tenant_id = ?", productID, tenantID).First(&product).Error; err != nil {
+			return nil, 0, errors.New("Product " + strconv.Itoa(int(productID)) + " not found")
+		}
+
+		var variantCount int64
+		db.Model(&models.Product{}).Where("parent_id = ?", product.ID).Count(&variantCount)
+		if variantCount > 0 {
+			return nil, 0, errors.New("Select a variant of " + product.Name)
+		}
+		// A variant's kitchen station and tax category come from its parent unless it has its own
+		inheritFromParent(db, &product)
+
+		// Items sold in a pack unit (e.g. "crate") are converted to base units
+		unit, err := resolveUnit(db, product, item.Unit)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		modifiers, options, err := resolveModifiers(db, product, item.Modifiers)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		item.ProductID = product.ID
+		item.Name = product.Name
+		item.Unit = unit.Name
+		item.Modifiers = modifiers
+		priced = append(priced, pricedItem{OrderItemRequest: item, Product: product, Unit: unit, Options: options})
+		totals[product.ID] = roundQty(totals[product.ID] + item.Quantity*unit.Factor)
+	}
+
+	subtotal := money.Amount(0)
+	for i := range priced {
+		item := &priced[i]
+		item.PriceTierID = nil
+		item.Discount = 0
+		item.Tax = 0
+
+		if item.Unit.Factor != 1 && item.Unit.Price > 0 {
+			// Pack units with their own price are not tiered
+			item.Price = item.Unit.Price
+		} else {
+			basePrice, tier := resolvePrice(db, item.Product, totals[item.Product.ID], pc)
+			item.Price = basePrice.MulQty(item.Unit.Factor)
+			if tier != nil {
+				item.PriceTierID = &tier.ID
+			}
+		}
+
+		// Modifiers are priced per item, whatever unit it is sold in
+		for _, modifier := range item.Modifiers {
+			item.Price += modifier.Price
+		}
+
+		item.Subtotal = item.Price.MulQty(item.Quantity).RoundTo(currencyUnit)
+		subtotal += item.Subtotal
+	}
+
+	return priced, subtotal, nil
+}
+
+// PriceTierRequest - Request body for creating or updating a price tier
+type PriceTierRequest struct {
+	MinQty        float64      `json:"min_qty" binding:"required,gt=0"`
+	Price         money.Amount `json:"price" binding:"required,gt=0"`
+	CustomerGroup string       `json:"customer_group"` // retail, wholesale, member; empty for everyone
+	Outlet        string       `json:"outlet"`         // Empty for every outlet
+}
+
+// validate normalizes the request and checks the customer group
+func (req *PriceTierRequest) validate() error {
+	req.CustomerGroup = strings.ToLower(strings.TrimSpace(req.CustomerGroup))
+	req.Outlet = strings.TrimSpace(req.Outlet)
+	if req.CustomerGroup != "" && !customerGroups[req.CustomerGroup] {
+		return errors.New("customer_group must be retail, wholesale or member")
+	}
+	return nil
+}
+
+// tierExists reports whether another tier of the product has the same break
+func tierExists(db *gorm.DB, productID uint, req PriceTierRequest, excludeID uint) bool {
+	var count int64
+	db.Model(&models.PriceTier{}).
+		Where("product_id = ? AND min_qty = ? AND customer_group = ? AND outlet = ? AND id <> ?",
+			productID, req.MinQty, req.CustomerGroup, req.Outlet, excludeID).
+		Count(&count)
+	return count > 0
+}
+
+// GetPriceTiers - GET /api/products/:id/price-tiers
+func GetPriceTiers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		var tiers []models.PriceTier
+		if err := db.Where("product_id = ?", product.ID).
+			Order("outlet ASC, customer_group ASC, min_qty ASC").
+			Find(&tiers).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, tiers)
+	}
+}
+
+// CreatePriceTier - POST /api/products/:id/price-tiers
+func CreatePriceTier(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PriceTierRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		if tierExists(db, product.ID, req, 0) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A tier with this quantity, group and outlet already exists"})
+			return
+		}
+
+		tier := models.PriceTier{
+			TenantID:      product.TenantID,
+			ProductID:     product.ID,
+			MinQty:        req.MinQty,
+			Price:         req.Price,
+			CustomerGroup: req.CustomerGroup,
+			Outlet:        req.Outlet,
+		}
+		if err := db.Create(&tier).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create price tier"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, tier)
+	}
+}
+
+// UpdatePriceTier - PUT /api/products/:id/price-tiers/:tierId
+func UpdatePriceTier(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PriceTierRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		var tier models.PriceTier
+		if err := db.Where("id = ? AND product_id = ?", c.Param("tierId"), product.ID).First(&tier).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Price tier not found"})
+			return
+		}
+
+		if tierExists(db, product.ID, req, tier.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A tier with this quantity, group and outlet already exists"})
+			return
+		}
+
+		tier.MinQty = req.MinQty
+		tier.Price = req.Price
+		tier.CustomerGroup = req.CustomerGroup
+		tier.Outlet = req.Outlet
+		if err := db.Save(&tier).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, tier)
+	}
+}
+
+// DeletePriceTier - DELETE /api/products/:id/price-tiers/:tierId
+func DeletePriceTier(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		if err := db.Where("id = ? AND product_id = ?", c.Param("tierId"), product.ID).Delete(&models.PriceTier{}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Price tier deleted"})
+	}
+}
+
+// GetProductPrice - GET /api/products/:id/price?quantity=10&unit=&customer_group=&outlet=
+// Quotes the price the server will charge, so the POS can show it before checkout.
+func GetProductPrice(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		quantity := 1.0
+		if q := c.Query("quantity"); q != "" {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil || parsed <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be a positive number"})
+				return
+			}
+			quantity = parsed
+		}
+
+		pc := priceContext{
+			CustomerGroup: strings.ToLower(c.DefaultQuery("customer_group", "retail")),
+			Outlet:        c.Query("outlet"),
+		}
+
+		items, _, err := priceOrderItems(db, product.TenantID, []OrderItemRequest{{
+			ProductID: product.ID,
+			Quantity:  quantity,
+			Unit:      c.Query("unit"),
+		}}, pc, loadTenantConfig(db, product.TenantID).currency().Unit())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, items[0].OrderItemRequest)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"ringpos-backend/internal/models"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -35,16 +36,29 @@ func GetProducts(db *gorm.DB) gin.HandlerFunc {
 			query = query.Where("category = ?", category)
 		}
 		
-		// Search by name
+		// Search by name or SKU
 		if search := c.Query("search"); search != "" {
-			query = query.Where("name LIKE ?", "%"+search+"%")
+			query = query.Where("name LIKE ? OR sku = ?", "%"+search+"%", search)
 		}
 		
+		// Variants are nested under their parent unless a flat list of sellable items is requested
+		if c.Query("flat") == "true" {
+			query = query.Where("id NOT IN (?)", db.Model(&models.Product{}).Select("parent_id").Where("parent_id IS NOT NULL"))
+		} else {
+			query = query.Where("parent_id IS NULL").Preload("Variants").Preload("Variants.Barcodes")
+		}
+		query = query.Preload("Barcodes").Preload("Units").Preload("PriceTiers")
+		
 		if err := query.Find(&products).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
 			return
 		}
 		
+		// Only what can be rung up right now
+		if c.Query("sellable") == "true" {
+			products = sellableProducts(db, products)
+		}
+		
 		c.JSON(http.StatusOK, products)
 	}
 }
@@ -55,7 +69,7 @@ func GetProduct(db *gorm.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		
 		var product models.Product
-		if err := db.First(&product, id).Error; err != nil {
+		if err := db.Preload("Barcodes").Preload("Units").Preload("PriceTiers").Preload("Variants").Preload("Variants.Barcodes").First(&product, id).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 			return
 		}
@@ -86,6 +100,57 @@ func CreateProduct(db *gorm.DB) gin.HandlerFunc {
 			}
 		}
 		
+		// SKU must be unique within the tenant
+		if skuTaken(db, product.TenantID, product.SKU, 0) {
+			c.JSON(http.StatusConflict, gin.H{"error": "SKU already exists"})
+			return
+		}
+		
+		// Validate barcodes sent as [{"code": "..."}]
+		var codes []string
+		for _, b := range product.Barcodes {
+			codes = append(codes, b.Code)
+		}
+		barcodes, err := buildProductBarcodes(db, product.TenantID, codes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		product.Barcodes = barcodes
+		
+		// Units and price tiers may be sent along with a new product
+		// and get the same checks as the units and price tier endpoints
+		unitNames := map[string]bool{}
+		for i := range product.Units {
+			unit := &product.Units[i]
+			unit.Name = strings.TrimSpace(unit.Name)
+			if unit.Name == "" || unit.Factor <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Units need a name and a positive factor"})
+				return
+			}
+			if strings.EqualFold(unit.Name, baseUnit(product)) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "The base unit does not need a conversion"})
+				return
+			}
+			if unitNames[strings.ToLower(unit.Name)] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unit " + unit.Name + " is listed twice"})
+				return
+			}
+			unitNames[strings.ToLower(unit.Name)] = true
+			unit.TenantID = product.TenantID
+		}
+		for i := range product.PriceTiers {
+			tier := &product.PriceTiers[i]
+			req := PriceTierRequest{MinQty: tier.MinQty, Price: tier.Price, CustomerGroup: tier.CustomerGroup, Outlet: tier.Outlet}
+			if err := req.validate(); err != nil || tier.MinQty <= 0 || tier.Price <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Price tiers need a positive min_qty and price and a valid customer_group"})
+				return
+			}
+			tier.TenantID = product.TenantID
+			tier.CustomerGroup = req.CustomerGroup
+			tier.Outlet = req.Outlet
+		}
+		
 		if err := db.Create(&product).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
 			return
@@ -125,6 +190,16 @@ func UpdateProduct(db *gorm.DB) gin.HandlerFunc {
 		// Prevent changing tenant_id
 		updateData.TenantID = product.TenantID
 		
+		// Barcodes, units and price tiers are managed through their own endpoints
+		updateData.Barcodes = nil
+		updateData.Units = nil
+		updateData.PriceTiers = nil
+		
+		if skuTaken(db, product.TenantID, updateData.SKU, product.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "SKU already exists"})
+			return
+		}
+		
 		// Update fields
 		db.Model(&product).Updates(updateData)
 		
@@ -153,7 +228,21 @@ func DeleteProduct(db *gorm.DB) gin.HandlerFunc {
 			}
 		}
 		
-		db.Delete(&product)
+		// Variants and the barcodes of both go with their parent, freeing the codes
+		err := db.Transaction(func(tx *gorm.DB) error {
+			variantIDs := tx.Model(&models.Product{}).Select("id").Where("parent_id = ?", product.ID)
+			if err := tx.Where("product_id = ? OR product_id IN (?)", product.ID, variantIDs).Delete(&models.ProductBarcode{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("parent_id = ?", product.ID).Delete(&models.Product{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&product).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 		
 		c.JSON(http.StatusOK, gin.H{"message": "Product deleted"})
 	}
@@ -165,7 +254,7 @@ func UpdateStock(db *gorm.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		
 		var stockUpdate struct {
-			Quantity int    `json:"quantity"`
+			Quantity float64 `json:"quantity"`
 			Action   string `json:"action"` // "add" or "subtract"
 		}
 		
@@ -181,9 +270,9 @@ func UpdateStock(db *gorm.DB) gin.HandlerFunc {
 		}
 		
 		if stockUpdate.Action == "add" {
-			product.Stock += stockUpdate.Quantity
+			product.Stock = roundQty(product.Stock + stockUpdate.Quantity)
 		} else if stockUpdate.Action == "subtract" {
-			product.Stock -= stockUpdate.Quantity
+			product.Stock = roundQty(product.Stock - stockUpdate.Quantity)
 			if product.Stock < 0 {
 				product.Stock = 0
 			}
@@ -199,8 +288,8 @@ func UpdateStock(db *gorm.DB) gin.HandlerFunc {
 func BulkUpdateStock(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var updates []struct {
-			ProductID uint `json:"product_id"`
-			Quantity  int  `json:"quantity"`
+			ProductID uint    `json:"product_id"`
+			Quantity  float64 `json:"quantity"`
 		}
 		
 		if err := c.ShouldBindJSON(&updates); err != nil {
@@ -219,7 +308,7 @@ func BulkUpdateStock(db *gorm.DB) gin.HandlerFunc {
 				return
 			}
 			
-			product.Stock -= update.Quantity
+			product.Stock = roundQty(product.Stock - update.Quantity)
 			if product.Stock < 0 {
 				product.Stock = 0
 			}
@@ -236,3 +325,23 @@ func BulkUpdateStock(db *gorm.DB) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "Stock updated successfully"})
 	}
 }
+
+// loadTenantProduct fetches the product in the URL and checks tenant access
+func loadTenantProduct(c *gin.Context, db *gorm.DB) (models.Product, bool) {
+	var product models.Product
+	if err := db.First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return product, false
+	}
+
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil || product.TenantID != *tenantID.(*uint) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return product, false
+		}
+	}
+
+	return product, true
+}
@@ -0,0 +1,530 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DiscountLine is one discount applied to an order and why
+type DiscountLine struct {
+	PromotionID *uint        `json:"promotion_id,omitempty"`
+	VoucherID   *uint        `json:"voucher_id,omitempty"`
+	Name        string       `json:"name"`
+	Reason      string       `json:"reason"`
+	Amount      money.Amount `json:"amount"`
+}
+
+// promotionRules is a Promotion with its JSON fields decoded
+type promotionRules struct {
+	models.Promotion
+	productIDs map[uint]bool
+	categories map[string]bool
+	days       map[time.Weekday]bool
+}
+
+func decodePromotion(p models.Promotion) promotionRules {
+	rules := promotionRules{
+		Promotion:  p,
+		productIDs: map[uint]bool{},
+		categories: map[string]bool{},
+		days:       map[time.Weekday]bool{},
+	}
+
+	var ids []uint
+	json.Unmarshal([]byte(p.ProductIDs), &ids)
+	for _, id := range ids {
+		rules.productIDs[id] = true
+	}
+
+	var categories []string
+	json.Unmarshal([]byte(p.Categories), &categories)
+	for _, category := range categories {
+		rules.categories[strings.ToLower(category)] = true
+	}
+
+	var days []int
+	json.Unmarshal([]byte(p.Days), &days)
+	for _, day := range days {
+		rules.days[time.Weekday(day)] = true
+	}
+
+	return rules
+}
+
+// activeAt reports whether the promotion runs at t, including its daily time window.
+// A window whose end is before its start runs past midnight.
+func (p promotionRules) activeAt(t time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.StartsAt != nil && t.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && t.After(*p.EndsAt) {
+		return false
+	}
+	if len(p.days) > 0 && !p.days[t.Weekday()] {
+		return false
+	}
+	if p.StartTime != "" && p.EndTime != "" {
+		now := t.Format("15:04")
+		if p.StartTime <= p.EndTime {
+			return now >= p.StartTime && now < p.EndTime
+		}
+		return now >= p.StartTime || now < p.EndTime
+	}
+	return true
+}
+
+// targets reports whether an order line is covered by the promotion.
+// Targeting a parent product covers all of its variants.
+func (p promotionRules) targets(item pricedItem) bool {
+	if len(p.productIDs) == 0 && len(p.categories) == 0 {
+		return true
+	}
+	if p.productIDs[item.Product.ID] {
+		return true
+	}
+	if item.Product.ParentID != nil && p.productIDs[*item.Product.ParentID] {
+		return true
+	}
+	return p.categories[strings.ToLower(item.Product.Category)]
+}
+
+// reason describes the promotion for receipts and the preview
+func (p promotionRules) reason() string {
+	var reason string
+	switch p.Type {
+	case "percentage":
+		reason = fmt.Sprintf("%g%% off", p.Value)
+	case "fixed":
+		reason = fmt.Sprintf("%s off", p.Amount)
+	case "buy_x_get_y":
+		if p.Value > 0 {
+			reason = fmt.Sprintf("Buy %d get %d at %g%% off", p.BuyQty, p.GetQty, p.Value)
+		} else {
+			reason = fmt.Sprintf("Buy %d get %d free", p.BuyQty, p.GetQty)
+		}
+	case "bundle":
+		reason = fmt.Sprintf("%d for %s", p.BuyQty, p.Amount)
+	}
+
+	if p.MinSpend > 0 {
+		reason += fmt.Sprintf(" on orders over %s", p.MinSpend)
+	}
+	if p.StartTime != "" && p.EndTime != "" {
+		reason += fmt.Sprintf(" (%s-%s)", p.StartTime, p.EndTime)
+	}
+	return reason
+}
+
+// promotionUnit is a single unit of an order line, for promotions counted per item
+type promotionUnit struct {
+	line  int
+	price money.Amount
+}
+
+// expandUnits lists the whole units of the given lines, most expensive first.
+// Fractions of weighed goods do not count towards item promotions.
+func expandUnits(items []pricedItem, lines []int) []promotionUnit {
+	var units []promotionUnit
+	for _, i := range lines {
+		for n := 0; n < int(items[i].Quantity); n++ {
+			units = append(units, promotionUnit{line: i, price: items[i].Price})
+		}
+	}
+	sort.SliceStable(units, func(a, b int) bool { return units[a].price > units[b].price })
+	return units
+}
+
+// allocateDiscount spreads amount over the given lines in proportion to what is
+// left of each, never more than a line has left
+func allocateDiscount(amount money.Amount, remaining []money.Amount, lines []int) map[int]money.Amount {
+	base := money.Amount(0)
+	weights := make([]money.Amount, len(lines))
+	for n, i := range lines {
+		weights[n] = remaining[i]
+		base += remaining[i]
+	}
+	if base <= 0 || amount <= 0 {
+		return nil
+	}
+	if amount > base {
+		amount = base
+	}
+
+	shares := map[int]money.Amount{}
+	for n, share := range money.Allocate(amount, weights) {
+		shares[lines[n]] = share
+	}
+	return shares
+}
+
+// promotionDiscount works out how much a promotion takes off each targeted line.
+// Percentages are rounded to the currency's smallest unit.
+func promotionDiscount(p promotionRules, items []pricedItem, remaining []money.Amount, lines []int, currencyUnit money.Amount) map[int]money.Amount {
+	switch p.Type {
+	case "percentage":
+		shares := map[int]money.Amount{}
+		for _, i := range lines {
+			shares[i] = remaining[i].Percent(p.Value).RoundTo(currencyUnit)
+		}
+		return shares
+
+	case "fixed":
+		return allocateDiscount(p.Amount, remaining, lines)
+
+	case "buy_x_get_y":
+		// The cheapest units of each full set are the ones given
+		units := expandUnits(items, lines)
+		sets := len(units) / (p.BuyQty + p.GetQty)
+		percent := 100.0
+		if p.Value > 0 {
+			percent = p.Value
+		}
+		shares := map[int]money.Amount{}
+		for _, unit := range units[len(units)-sets*p.GetQty:] {
+			shares[unit.line] += unit.price.Percent(percent).RoundTo(currencyUnit)
+		}
+		return shares
+
+	case "bundle":
+		units := expandUnits(items, lines)
+		bundles := len(units) / p.BuyQty
+		regular := money.Amount(0)
+		inBundle := map[int]bool{}
+		var bundleLines []int
+		for _, unit := range units[:bundles*p.BuyQty] {
+			regular += unit.price
+			if !inBundle[unit.line] {
+				inBundle[unit.line] = true
+				bundleLines = append(bundleLines, unit.line)
+			}
+		}
+		return allocateDiscount(regular-money.Amount(bundles)*p.Amount, remaining, bundleLines)
+	}
+	return nil
+}
+
+// applyPromotions applies a tenant's promotions running at now to priced order lines,
+// setting each line's Discount and returning the discount lines.
+// Promotions apply by priority; one that does not stack only applies when no other
+// promotion has, and stops any after it.
+func applyPromotions(db *gorm.DB, tenantID uint, items []pricedItem, subtotal money.Amount, now time.Time, currencyUnit money.Amount) []DiscountLine {
+	var promotions []models.Promotion
+	db.Where("tenant_id = ? AND active = ?", tenantID, true).
+		Order("priority DESC, id ASC").
+		Find(&promotions)
+
+	remaining := make([]money.Amount, len(items))
+	for i, item := range items {
+		remaining[i] = item.Subtotal - item.Discount
+	}
+
+	var discounts []DiscountLine
+	for _, promotion := range promotions {
+		p := decodePromotion(promotion)
+		if !p.activeAt(now) || subtotal < p.MinSpend {
+			continue
+		}
+		if len(discounts) > 0 && !p.Stackable {
+			continue
+		}
+
+		var lines []int
+		for i, item := range items {
+			if p.targets(item) {
+				lines = append(lines, i)
+			}
+		}
+
+		amount := money.Amount(0)
+		for i, share := range promotionDiscount(p, items, remaining, lines, currencyUnit) {
+			if share > remaining[i] {
+				share = remaining[i]
+			}
+			if share <= 0 {
+				continue
+			}
+			remaining[i] -= share
+			items[i].Discount += share
+			amount += share
+		}
+		if amount <= 0 {
+			continue
+		}
+
+		promotionID := p.ID
+		discounts = append(discounts, DiscountLine{
+			PromotionID: &promotionID,
+			Name:        p.Name,
+			Reason:      p.reason(),
+			Amount:      amount,
+		})
+		if !p.Stackable {
+			break
+		}
+	}
+
+	return discounts
+}
+
+// applyOrderDiscount takes a fixed amount off the whole order, spread over the lines
+func applyOrderDiscount(items []pricedItem, amount money.Amount, name, reason string) *DiscountLine {
+	remaining := make([]money.Amount, len(items))
+	lines := make([]int, len(items))
+	for i, item := range items {
+		remaining[i] = item.Subtotal - item.Discount
+		lines[i] = i
+	}
+
+	total := money.Amount(0)
+	for i, share := range allocateDiscount(amount, remaining, lines) {
+		items[i].Discount += share
+		total += share
+	}
+	if total <= 0 {
+		return nil
+	}
+	return &DiscountLine{Name: name, Reason: reason, Amount: total}
+}
+
+// PromotionRequest - Request body for creating or updating a promotion
+type PromotionRequest struct {
+	Name       string       `json:"name" binding:"required"`
+	Type       string       `json:"type" binding:"required,oneof=percentage fixed buy_x_get_y bundle"`
+	Value      float64      `json:"value" binding:"gte=0"`  // Percent
+	Amount     money.Amount `json:"amount" binding:"gte=0"` // Amount off for fixed; bundle price
+	BuyQty     int          `json:"buy_qty" binding:"gte=0"`
+	GetQty     int          `json:"get_qty" binding:"gte=0"`
+	ProductIDs []uint       `json:"product_ids"`
+	Categories []string     `json:"categories"`
+	MinSpend   money.Amount `json:"min_spend" binding:"gte=0"`
+	StartsAt   string       `json:"starts_at"` // YYYY-MM-DD or RFC3339
+	EndsAt     string       `json:"ends_at"`   // A plain date runs to the end of that day
+	Days       []int        `json:"days"`      // 0 = Sunday
+	StartTime  string       `json:"start_time"`
+	EndTime    string       `json:"end_time"`
+	Stackable  bool         `json:"stackable"`
+	Priority   int          `json:"priority"`
+	Active     *bool        `json:"active"` // Defaults to true
+}
+
+// apply validates the request and copies it onto a promotion
+func (req PromotionRequest) apply(p *models.Promotion) error {
+	switch req.Type {
+	case "percentage":
+		if req.Value <= 0 || req.Value > 100 {
+			return errors.New("A percentage promotion needs a value between 0 and 100")
+		}
+	case "fixed":
+		if req.Amount <= 0 {
+			return errors.New("A fixed promotion needs a positive amount")
+		}
+	case "buy_x_get_y":
+		if req.BuyQty <= 0 || req.GetQty <= 0 || req.Value > 100 {
+			return errors.New("A buy X get Y promotion needs buy_qty and get_qty, and a value of at most 100 percent")
+		}
+	case "bundle":
+		if req.BuyQty < 2 || req.Amount <= 0 {
+			return errors.New("A bundle needs at least 2 items (buy_qty) and a bundle price (amount)")
+		}
+	}
+
+	startsAt, err := parseDate(req.StartsAt)
+	if err != nil {
+		return err
+	}
+	endsAt, err := parseDate(req.EndsAt)
+	if err != nil {
+		return err
+	}
+	if endsAt != nil && len(req.EndsAt) == len("2006-01-02") {
+		end := endsAt.Add(24*time.Hour - time.Second)
+		endsAt = &end
+	}
+
+	if (req.StartTime == "") != (req.EndTime == "") {
+		return errors.New("start_time and end_time must be set together")
+	}
+	for _, hhmm := range []string{req.StartTime, req.EndTime} {
+		if hhmm != "" && !validClock(hhmm) {
+			return errors.New("Times must be HH:MM, e.g. 09:00")
+		}
+	}
+	for _, day := range req.Days {
+		if day < 0 || day > 6 {
+			return errors.New("Days must be 0 (Sunday) to 6 (Saturday)")
+		}
+	}
+
+	p.Name = req.Name
+	p.Type = req.Type
+	p.Value = req.Value
+	p.Amount = req.Amount
+	p.BuyQty = req.BuyQty
+	p.GetQty = req.GetQty
+	p.ProductIDs = ""
+	if len(req.ProductIDs) > 0 {
+		b, _ := json.Marshal(req.ProductIDs)
+		p.ProductIDs = string(b)
+	}
+	p.Categories = ""
+	if len(req.Categories) > 0 {
+		b, _ := json.Marshal(req.Categories)
+		p.Categories = string(b)
+	}
+	p.Days = ""
+	if len(req.Days) > 0 {
+		b, _ := json.Marshal(req.Days)
+		p.Days = string(b)
+	}
+	p.MinSpend = req.MinSpend
+	p.StartsAt = startsAt
+	p.EndsAt = endsAt
+	p.StartTime = req.StartTime
+	p.EndTime = req.EndTime
+	p.Stackable = req.Stackable
+	p.Priority = req.Priority
+	p.Active = req.Active == nil || *req.Active
+	return nil
+}
+
+// GetPromotions - GET /api/promotions
+func GetPromotions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var promotions []models.Promotion
+
+		query := db.Order("priority DESC, id ASC")
+
+		// Tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if exists && tenantID != nil {
+				query = query.Where("tenant_id = ?", *tenantID.(*uint))
+			}
+		}
+
+		if c.Query("active") == "true" {
+			query = query.Where("active = ?", true)
+		}
+
+		if err := query.Find(&promotions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, promotions)
+	}
+}
+
+// CreatePromotion - POST /api/promotions
+func CreatePromotion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PromotionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		promotion := models.Promotion{TenantID: *tenantID.(*uint)}
+		if err := req.apply(&promotion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Create(&promotion).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create promotion"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, promotion)
+	}
+}
+
+// UpdatePromotion - PUT /api/promotions/:id
+func UpdatePromotion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var promotion models.Promotion
+
+		if err := db.First(&promotion, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			return
+		}
+
+		// Check tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if !exists || tenantID == nil || promotion.TenantID != *tenantID.(*uint) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+		}
+
+		var req PromotionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := req.apply(&promotion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Save(&promotion).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, promotion)
+	}
+}
+
+// DeletePromotion - DELETE /api/promotions/:id
+func DeletePromotion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var promotion models.Promotion
+
+		if err := db.First(&promotion, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			return
+		}
+
+		// Check tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if !exists || tenantID == nil || promotion.TenantID != *tenantID.(*uint) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+		}
+
+		if err := db.Delete(&promotion).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Promotion deleted"})
+	}
+}
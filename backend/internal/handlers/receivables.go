@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// chargeAccount puts an amount on a customer's account. The credit limit is
+// checked in the update itself, so two tills cannot both use the last of it.
+func chargeAccount(tx *gorm.DB, customer *models.Customer, entry models.ReceivableEntry) error {
+	result := tx.Model(&models.Customer{}).
+		Where("id = ? AND balance + ? <= credit_limit", customer.ID, entry.Amount).
+		UpdateColumn("balance", gorm.Expr("balance + ?", entry.Amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Credit limit exceeded")
+	}
+	if err := tx.First(customer, customer.ID).Error; err != nil {
+		return err
+	}
+
+	// Store credit pays for the charge straight away; only what is left owing is open
+	entry.TenantID = customer.TenantID
+	entry.CustomerID = customer.ID
+	entry.Type = "charge"
+	entry.Open = min(entry.Amount, max(customer.Balance, 0))
+	return tx.Create(&entry).Error
+}
+
+// settleCharges marks amount of a customer's open charges as paid, oldest first
+func settleCharges(tx *gorm.DB, customerID uint, amount money.Amount) error {
+	var charges []models.ReceivableEntry
+	if err := tx.Where("customer_id = ? AND open > 0", customerID).Order("created_at ASC, id ASC").Find(&charges).Error; err != nil {
+		return err
+	}
+	for _, charge := range charges {
+		if amount == 0 {
+			break
+		}
+		settled := min(charge.Open, amount)
+		if err := tx.Model(&charge).UpdateColumn("open", charge.Open-settled).Error; err != nil {
+			return err
+		}
+		amount -= settled
+	}
+	return nil
+}
+
+// receivePayment takes a payment off a customer's balance and settles their
+// oldest charges with it. Paying more than is owed leaves store credit.
+func receivePayment(tx *gorm.DB, customer *models.Customer, entry models.ReceivableEntry) error {
+	amount := entry.Amount
+	if err := tx.Model(&models.Customer{}).Where("id = ?", customer.ID).
+		UpdateColumn("balance", gorm.Expr("balance - ?", amount)).Error; err != nil {
+		return err
+	}
+	customer.Balance -= amount
+	if err := settleCharges(tx, customer.ID, amount); err != nil {
+		return err
+	}
+
+	entry.TenantID = customer.TenantID
+	entry.CustomerID = customer.ID
+	entry.Type = "payment"
+	entry.Amount = -amount
+	return tx.Create(&entry).Error
+}
+
+// ReceivePaymentRequest - Request body for a payment against a customer's account
+type ReceivePaymentRequest struct {
+	Amount        money.Amount `json:"amount" binding:"gt=0"`
+	PaymentMethod string       `json:"payment_method" binding:"required"`
+	Note          string       `json:"note"`
+}
+
+// ReceiveCustomerPayment - POST /api/customers/:id/payments
+// Records money received on account, or paid in advance as store credit.
+func ReceiveCustomerPayment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ReceivePaymentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.PaymentMethod == tenderOnAccount {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "An account cannot be paid on account"})
+			return
+		}
+
+		customer, ok := loadTenantCustomer(c, db)
+		if !ok {
+			return
+		}
+
+		entry := models.ReceivableEntry{
+			Amount:        req.Amount,
+			PaymentMethod: req.PaymentMethod,
+			Note:          req.Note,
+			UserID:        c.GetUint("user_id"),
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return receivePayment(tx, &customer, entry)
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, customer)
+	}
+}
+
+// StatementLine - A ledger entry with the balance after it
+type StatementLine struct {
+	models.ReceivableEntry
+	Balance money.Amount `json:"balance"`
+}
+
+// GetCustomerStatement - GET /api/customers/:id/statement?date_from=&date_to=
+// Lists charges and payments in the period with a running balance.
+func GetCustomerStatement(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		customer, ok := loadTenantCustomer(c, db)
+		if !ok {
+			return
+		}
+
+		from, err := parseDate(c.Query("date_from"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		to, err := parseDate(c.Query("date_to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var opening money.Amount
+		query := db.Where("customer_id = ?", customer.ID)
+		if from != nil {
+			db.Model(&models.ReceivableEntry{}).Select("COALESCE(SUM(amount), 0)").
+				Where("customer_id = ? AND created_at < ?", customer.ID, *from).
+				Scan(&opening)
+			query = query.Where("created_at >= ?", *from)
+		}
+		if to != nil {
+			// A plain date covers the whole day
+			if len(c.Query("date_to")) == len("2006-01-02") {
+				*to = to.AddDate(0, 0, 1)
+			}
+			query = query.Where("created_at < ?", *to)
+		}
+
+		var entries []models.ReceivableEntry
+		if err := query.Order("created_at ASC, id ASC").Find(&entries).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		lines := make([]StatementLine, len(entries))
+		balance := opening
+		var charged, paid money.Amount
+		for i, entry := range entries {
+			balance += entry.Amount
+			if entry.Amount > 0 {
+				charged += entry.Amount
+			} else {
+				paid -= entry.Amount
+			}
+			lines[i] = StatementLine{ReceivableEntry: entry, Balance: balance}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"customer_id":      customer.ID,
+			"customer_name":    customer.Name,
+			"credit_limit":     customer.CreditLimit,
+			"available_credit": max(customer.CreditLimit-customer.Balance, 0),
+			"opening_balance":  opening,
+			"charged":          charged,
+			"paid":             paid,
+			"closing_balance":  balance,
+			"entries":          lines,
+		})
+	}
+}
+
+// AgingBuckets - What a customer owes by how long the charges have been open
+type AgingBuckets struct {
+	Current  money.Amount `json:"current"` // Up to 30 days
+	Days31   money.Amount `json:"days_31_60"`
+	Days61   money.Amount `json:"days_61_90"`
+	Over90   money.Amount `json:"over_90"`
+	Total    money.Amount `json:"total"`
+	Earliest *time.Time   `json:"earliest"` // Oldest unpaid charge
+}
+
+// add puts an open charge into its bucket by age at asOf
+func (b *AgingBuckets) add(open money.Amount, charged, asOf time.Time) {
+	switch days := int(asOf.Sub(charged).Hours() / 24); {
+	case days <= 30:
+		b.Current += open
+	case days <= 60:
+		b.Days31 += open
+	case days <= 90:
+		b.Days61 += open
+	default:
+		b.Over90 += open
+	}
+	b.Total += open
+	if b.Earliest == nil || charged.Before(*b.Earliest) {
+		t := charged
+		b.Earliest = &t
+	}
+}
+
+// CustomerAging - A customer's open balance in the aging report
+type CustomerAging struct {
+	CustomerID  uint         `json:"customer_id"`
+	Name        string       `json:"name"`
+	Phone       string       `json:"phone"`
+	CreditLimit money.Amount `json:"credit_limit"`
+	Balance     money.Amount `json:"balance"`
+	AgingBuckets
+}
+
+// GetReceivablesAging - GET /api/receivables/aging?as_of=
+// Open charges per customer in 30 day buckets, largest balances first. as_of ages
+// what is open now at a later date, such as a month end; the ledger only keeps
+// what is open today, so past dates are refused.
+func GetReceivablesAging(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		asOf := time.Now()
+		if t, err := parseDate(c.Query("as_of")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if t != nil {
+			today := asOf.In(loadTenantConfig(db, *tenantID.(*uint)).location()).Format("2006-01-02")
+			if t.Format("2006-01-02") < today {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "as_of cannot be in the past"})
+				return
+			}
+			asOf = *t
+		}
+
+		var charges []models.ReceivableEntry
+		if err := db.Where("tenant_id = ? AND open > 0", *tenantID.(*uint)).Find(&charges).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		byCustomer := map[uint]*CustomerAging{}
+		var totals AgingBuckets
+		for _, charge := range charges {
+			row := byCustomer[charge.CustomerID]
+			if row == nil {
+				row = &CustomerAging{CustomerID: charge.CustomerID}
+				byCustomer[charge.CustomerID] = row
+			}
+			row.add(charge.Open, charge.CreatedAt, asOf)
+			totals.add(charge.Open, charge.CreatedAt, asOf)
+		}
+
+		ids := make([]uint, 0, len(byCustomer))
+		for id := range byCustomer {
+			ids = append(ids, id)
+		}
+		var customers []models.Customer
+		db.Where("id IN ?", ids).Find(&customers)
+		for _, customer := range customers {
+			row := byCustomer[customer.ID]
+			row.Name = customer.Name
+			row.Phone = customer.Phone
+			row.CreditLimit = customer.CreditLimit
+			row.Balance = customer.Balance
+		}
+
+		rows := make([]CustomerAging, 0, len(byCustomer))
+		for _, row := range byCustomer {
+			rows = append(rows, *row)
+		}
+		sort.Slice(rows, func(a, b int) bool {
+			if rows[a].Total != rows[b].Total {
+				return rows[a].Total > rows[b].Total
+			}
+			return rows[a].CustomerID < rows[b].CustomerID
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			"as_of":     asOf.Format("2006-01-02"),
+			"customers": rows,
+			"totals":    totals,
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"ringpos-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// productRecipe loads the ingredients used up by one base unit of a product
+func productRecipe(db *gorm.DB, productID uint) ([]models.RecipeLine, error) {
+	var lines []models.RecipeLine
+	err := db.Where("product_id = ?", productID).Preload("Ingredient").Order("id ASC").Find(&lines).Error
+	return lines, err
+}
+
+// RecipeLineRequest - One ingredient of a recipe
+type RecipeLineRequest struct {
+	IngredientID uint    `json:"ingredient_id" binding:"required"`
+	Quantity     float64 `json:"quantity" binding:"required,gt=0"` // In the ingredient's base unit
+}
+
+// SetRecipeRequest - Request body for replacing a product's recipe; an empty list removes it
+type SetRecipeRequest struct {
+	Lines []RecipeLineRequest `json:"lines" binding:"dive"`
+}
+
+// validate checks that the ingredients belong to the tenant and are plain stock
+// items, so a sale never has to follow recipes within recipes
+func (req SetRecipeRequest) validate(db *gorm.DB, product models.Product) error {
+	if len(req.Lines) > 0 {
+		var count int64
+		db.Model(&models.RecipeLine{}).Where("ingredient_id = ?", product.ID).Count(&count)
+		if count > 0 {
+			return errors.New(product.Name + " is an ingredient of another recipe")
+		}
+	}
+
+	seen := map[uint]bool{}
+	for _, line := range req.Lines {
+		if line.IngredientID == product.ID {
+			return errors.New("A product cannot be an ingredient of itself")
+		}
+		if seen[line.IngredientID] {
+			return fmt.Errorf("Ingredient %d is listed twice", line.IngredientID)
+		}
+		seen[line.IngredientID] = true
+
+		var ingredient models.Product
+		if err := db.Where("id = ? AND tenant_id = ?", line.IngredientID, product.TenantID).First(&ingredient).Error; err != nil {
+			return fmt.Errorf("Ingredient %d not found", line.IngredientID)
+		}
+		var count int64
+		db.Model(&models.RecipeLine{}).Where("product_id = ?", ingredient.ID).Count(&count)
+		if count > 0 {
+			return errors.New(ingredient.Name + " has a recipe of its own")
+		}
+	}
+	return nil
+}
+
+// GetProductRecipe - GET /api/products/:id/recipe
+func GetProductRecipe(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		lines, err := productRecipe(db, product.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, lines)
+	}
+}
+
+// SetProductRecipe - PUT /api/products/:id/recipe
+func SetProductRecipe(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SetRecipeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+		if err := req.validate(db, product); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("product_id = ?", product.ID).Delete(&models.RecipeLine{}).Error; err != nil {
+				return err
+			}
+			for _, line := range req.Lines {
+				if err := tx.Create(&models.RecipeLine{
+					TenantID:     product.TenantID,
+					ProductID:    product.ID,
+					IngredientID: line.IngredientID,
+					Quantity:     line.Quantity,
+				}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		lines, _ := productRecipe(db, product.ID)
+		c.JSON(http.StatusOK, lines)
+	}
+}
+
+// IngredientUsage - How much of an ingredient was used in a period. Theoretical
+// usage is what sales say was used, through recipes, modifiers or selling the
+// item itself; actual usage is what left the shelf, so it also counts waste and
+// stock count differences.
+type IngredientUsage struct {
+	ProductID   uint    `json:"product_id"`
+	Name        string  `json:"name"`
+	Unit        string  `json:"unit"`
+	Opening     float64 `json:"opening"`
+	Received    float64 `json:"received"` // Restocks and other additions
+	Closing     float64 `json:"closing"`
+	Theoretical float64 `json:"theoretical"`
+	Actual      float64 `json:"actual"`   // Opening + Received - Closing
+	Variance    float64 `json:"variance"` // Actual - Theoretical; positive when more went than was sold
+	VariancePct float64 `json:"variance_pct"`
+}
+
+// GetIngredientUsage - GET /api/stock/usage?date_from=&date_to=&product_id=
+// Compares theoretical and actual usage of the tenant's ingredients, or of one product.
+func GetIngredientUsage(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		from, err := parseDate(c.Query("date_from"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		to, err := parseDate(c.Query("date_to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// A plain date covers the whole day
+		if to != nil && len(c.Query("date_to")) == len("2006-01-02") {
+			*to = to.AddDate(0, 0, 1)
+		}
+
+		// Ingredients are what recipes and modifiers use up
+		query := db.Where("tenant_id = ?", *tenantID.(*uint)).Order("name ASC")
+		if productID := c.Query("product_id"); productID != "" {
+			query = query.Where("id = ?", productID)
+		} else {
+			query = query.Where("id IN (?) OR id IN (?)",
+				db.Model(&models.RecipeLine{}).Select("ingredient_id"),
+				db.Model(&models.Modifier{}).Select("stock_product_id").Where("stock_product_id IS NOT NULL"))
+		}
+		var products []models.Product
+		if err := query.Find(&products).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		usage := make([]IngredientUsage, 0, len(products))
+		for _, product := range products {
+			var logs []models.StockLog
+			logQuery := db.Where("product_id = ?", product.ID)
+			if from != nil {
+				logQuery = logQuery.Where("created_at >= ?", *from)
+			}
+			if err := logQuery.Find(&logs).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Stock at the end of the period is today's stock less what changed since
+			row := IngredientUsage{ProductID: product.ID, Name: product.Name, Unit: product.Unit, Closing: product.Stock}
+			var changed float64
+			for _, log := range logs {
+				if to != nil && !log.CreatedAt.Before(*to) {
+					row.Closing -= log.ChangeAmount
+					continue
+				}
+				changed += log.ChangeAmount
+				switch log.Type {
+				case "sale":
+					// Sales are counted in full, even where stock ran out
+					row.Theoretical += log.Shortfall - log.ChangeAmount
+				case "adjustment":
+				default:
+					row.Received += log.ChangeAmount
+				}
+			}
+
+			row.Closing = roundQty(row.Closing)
+			row.Opening = roundQty(row.Closing - changed)
+			row.Received = roundQty(row.Received)
+			row.Theoretical = roundQty(row.Theoretical)
+			row.Actual = roundQty(row.Opening + row.Received - row.Closing)
+			row.Variance = roundQty(row.Actual - row.Theoretical)
+			if row.Theoretical != 0 {
+				row.VariancePct = roundQty(row.Variance / row.Theoretical * 100)
+			}
+			usage = append(usage, row)
+		}
+
+		c.JSON(http.StatusOK, usage)
+	}
+}
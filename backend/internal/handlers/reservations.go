@@ -0,0 +1,478 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"ringpos-backend/internal/phone"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// activeReservationStatuses are the bookings that hold a table
+var activeReservationStatuses = []string{"booked", "seated"}
+
+// reservationConfig is the booking policy in the tenant settings
+type reservationConfig struct {
+	Duration     int `json:"duration"`      // Minutes a table is held when a booking gives none; defaults to 90
+	HoldMinutes  int `json:"hold_minutes"`  // The table shows as reserved this long before a booking; defaults to 30
+	GraceMinutes int `json:"grace_minutes"` // A party this late is a no-show; defaults to 15
+}
+
+// applyDefaults fills in the policy left unset
+func (r *reservationConfig) applyDefaults() {
+	if r.Duration == 0 {
+		r.Duration = 90
+	}
+	if r.HoldMinutes == 0 {
+		r.HoldMinutes = 30
+	}
+	if r.GraceMinutes == 0 {
+		r.GraceMinutes = 15
+	}
+}
+
+// validate checks the minutes are sensible
+func (r reservationConfig) validate() error {
+	if r.Duration < 0 || r.HoldMinutes < 0 || r.GraceMinutes < 0 {
+		return errors.New("reservation minutes cannot be negative")
+	}
+	return nil
+}
+
+// reservationEnd is when a booking stops holding its table
+func reservationEnd(r models.Reservation) time.Time {
+	return r.ReservedAt.Add(time.Duration(r.Duration) * time.Minute)
+}
+
+// overlappingReservations lists the active bookings of a tenant that overlap r,
+// other than r itself. Bookings are at most a day long, which bounds the search.
+func overlappingReservations(db *gorm.DB, r models.Reservation) ([]models.Reservation, error) {
+	var candidates []models.Reservation
+	if err := db.Where("tenant_id = ? AND id <> ? AND status IN ?", r.TenantID, r.ID, activeReservationStatuses).
+		Where("reserved_at < ? AND reserved_at > ?", reservationEnd(r), r.ReservedAt.Add(-24*time.Hour)).
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	overlapping := candidates[:0]
+	for _, other := range candidates {
+		if reservationEnd(other).After(r.ReservedAt) {
+			overlapping = append(overlapping, other)
+		}
+	}
+	return overlapping, nil
+}
+
+// checkReservation makes sure a booking fits: its table is free for the whole
+// stay and seats the party, or its area has seats left at that time. The status
+// code says whether the request is wrong or the time is taken.
+func checkReservation(db *gorm.DB, r *models.Reservation) (int, error) {
+	overlapping, err := overlappingReservations(db, *r)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if r.TableID != nil {
+		var table models.Table
+		if err := db.Where("id = ? AND tenant_id = ?", *r.TableID, r.TenantID).First(&table).Error; err != nil {
+			return http.StatusBadRequest, errors.New("Table not found")
+		}
+		if table.Seats > 0 && r.PartySize > table.Seats {
+			return http.StatusBadRequest, fmt.Errorf("%s seats %d", table.Name, table.Seats)
+		}
+		for _, other := range overlapping {
+			if other.TableID != nil && *other.TableID == table.ID {
+				return http.StatusConflict, fmt.Errorf("%s is booked from %s", table.Name, other.ReservedAt.Format("15:04"))
+			}
+		}
+		r.AreaID = &table.AreaID
+		return 0, nil
+	}
+
+	if r.AreaID == nil {
+		return http.StatusBadRequest, errors.New("Select a table or an area")
+	}
+	var area models.Area
+	if err := db.Where("id = ? AND tenant_id = ?", *r.AreaID, r.TenantID).First(&area).Error; err != nil {
+		return http.StatusBadRequest, errors.New("Area not found")
+	}
+	var seats int
+	db.Model(&models.Table{}).Select("COALESCE(SUM(seats), 0)").Where("area_id = ? AND deleted_at IS NULL", area.ID).Scan(&seats)
+	booked := r.PartySize
+	for _, other := range overlapping {
+		if other.AreaID != nil && *other.AreaID == area.ID {
+			booked += other.PartySize
+		}
+	}
+	if booked > seats {
+		return http.StatusConflict, fmt.Errorf("%s is fully booked at that time", area.Name)
+	}
+	return 0, nil
+}
+
+// noShowCutoff is when a party booked before it is late enough to be a no-show
+func noShowCutoff(cfg reservationConfig, now time.Time) time.Time {
+	return now.Add(-time.Duration(cfg.GraceMinutes) * time.Minute).UTC()
+}
+
+// isNoShow reports whether a booking's party is past the grace period. Reads
+// show such bookings as no-shows; writes close them with markNoShows.
+func isNoShow(r models.Reservation, cfg reservationConfig, now time.Time) bool {
+	return r.Status == "booked" && r.ReservedAt.Before(noShowCutoff(cfg, now))
+}
+
+// markNoShows closes the bookings of a tenant whose party is past the grace
+// period, so they stop holding tables. It runs before bookings are written.
+func markNoShows(db *gorm.DB, tenantID uint, cfg reservationConfig, now time.Time) error {
+	return db.Model(&models.Reservation{}).
+		Where("tenant_id = ? AND status = ? AND reserved_at < ?", tenantID, "booked", noShowCutoff(cfg, now)).
+		Update("status", "no_show").Error
+}
+
+// upcomingReservations returns the next booking of each table, for the floor plan.
+// Parties past the grace period no longer hold their tables.
+func upcomingReservations(db *gorm.DB, tableIDs []uint, now time.Time) map[uint]models.Reservation {
+	var reservations []models.Reservation
+	db.Where("table_id IN ? AND status = ? AND reserved_at >= ?", tableIDs, "booked", now.Add(-24*time.Hour).UTC()).
+		Order("reserved_at ASC").Find(&reservations)
+
+	configs := map[uint]reservationConfig{}
+	next := map[uint]models.Reservation{}
+	for _, r := range reservations {
+		cfg, loaded := configs[r.TenantID]
+		if !loaded {
+			cfg = loadTenantConfig(db, r.TenantID).Reservations
+			configs[r.TenantID] = cfg
+		}
+		if _, seen := next[*r.TableID]; !seen && reservationEnd(r).After(now) && !isNoShow(r, cfg, now) {
+			next[*r.TableID] = r
+		}
+	}
+	return next
+}
+
+// loadTenantReservation fetches the reservation in the URL and checks tenant access
+func loadTenantReservation(c *gin.Context, db *gorm.DB) (models.Reservation, bool) {
+	var reservation models.Reservation
+	if err := db.First(&reservation, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+		return reservation, false
+	}
+
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil || reservation.TenantID != *tenantID.(*uint) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return reservation, false
+		}
+	}
+
+	return reservation, true
+}
+
+// ReservationRequest - Request body for creating or updating a reservation
+type ReservationRequest struct {
+	CustomerID    *uint        `json:"customer_id"`
+	CustomerName  string       `json:"customer_name"` // Taken from the customer when customer_id is set
+	CustomerPhone string       `json:"customer_phone"`
+	PartySize     int          `json:"party_size" binding:"required,gt=0"`
+	ReservedAt    time.Time    `json:"reserved_at" binding:"required"` // RFC3339
+	Duration      int          `json:"duration" binding:"gte=0"`       // Minutes; defaults to the tenant's
+	TableID       *uint        `json:"table_id"`
+	AreaID        *uint        `json:"area_id"` // When the table is picked on arrival
+	Deposit       money.Amount `json:"deposit" binding:"gte=0"`
+	DepositMethod string       `json:"deposit_method"`
+	Note          string       `json:"note"`
+}
+
+// apply validates the request and copies it onto a reservation. The status code
+// says whether the request is wrong or the time is taken.
+func (req ReservationRequest) apply(db *gorm.DB, cfg tenantConfig, r *models.Reservation) (int, error) {
+	r.CustomerID = req.CustomerID
+	r.CustomerName = req.CustomerName
+	r.CustomerPhone = req.CustomerPhone
+	if req.CustomerID != nil {
+		var customer models.Customer
+		if err := db.Where("id = ? AND tenant_id = ?", *req.CustomerID, r.TenantID).First(&customer).Error; err != nil {
+			return http.StatusBadRequest, errors.New("Customer not found")
+		}
+		if r.CustomerName == "" {
+			r.CustomerName = customer.Name
+		}
+		if r.CustomerPhone == "" {
+			r.CustomerPhone = customer.Phone
+		}
+	}
+	if r.CustomerName == "" {
+		return http.StatusBadRequest, errors.New("customer_name is required")
+	}
+	if r.CustomerPhone != "" {
+		normalized, err := phone.Normalize(r.CustomerPhone, cfg.PhoneCountryCode)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		r.CustomerPhone = normalized
+	}
+
+	if req.Duration == 0 {
+		req.Duration = cfg.Reservations.Duration
+	}
+	if req.Duration > 24*60 {
+		return http.StatusBadRequest, errors.New("duration cannot be more than a day")
+	}
+	if req.Deposit > 0 && req.DepositMethod == "" {
+		return http.StatusBadRequest, errors.New("deposit_method is required with a deposit")
+	}
+
+	r.PartySize = req.PartySize
+	r.ReservedAt = req.ReservedAt.UTC() // Stored in UTC so times compare in the database
+	r.Duration = req.Duration
+	r.TableID = req.TableID
+	r.AreaID = req.AreaID
+	r.Deposit = req.Deposit
+	r.DepositMethod = req.DepositMethod
+	r.Note = req.Note
+	return checkReservation(db, r)
+}
+
+// GetReservations - GET /api/reservations?date=&status=&table_id=
+// Lists a day's bookings, today by default, in time order. Bookings past the
+// grace period are listed as no-shows.
+func GetReservations(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		now := time.Now()
+		cfg := loadTenantConfig(db, *tenantID.(*uint))
+		loc := cfg.location()
+		day, err := time.ParseInLocation("2006-01-02", c.DefaultQuery("date", now.In(loc).Format("2006-01-02")), loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date, use YYYY-MM-DD"})
+			return
+		}
+
+		query := db.Where("tenant_id = ? AND reserved_at >= ? AND reserved_at < ?", *tenantID.(*uint), day.UTC(), day.AddDate(0, 0, 1).UTC()).
+			Order("reserved_at ASC, id ASC")
+		if tableID := c.Query("table_id"); tableID != "" {
+			query = query.Where("table_id = ?", tableID)
+		}
+
+		var reservations []models.Reservation
+		if err := query.Find(&reservations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The status filter applies to the status as shown
+		status := c.Query("status")
+		listed := []models.Reservation{}
+		for _, r := range reservations {
+			if isNoShow(r, cfg.Reservations, now) {
+				r.Status = "no_show"
+			}
+			if status == "" || r.Status == status {
+				listed = append(listed, r)
+			}
+		}
+
+		c.JSON(http.StatusOK, listed)
+	}
+}
+
+// GetReservation - GET /api/reservations/:id
+func GetReservation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reservation, ok := loadTenantReservation(c, db)
+		if !ok {
+			return
+		}
+		if isNoShow(reservation, loadTenantConfig(db, reservation.TenantID).Reservations, time.Now()) {
+			reservation.Status = "no_show"
+		}
+
+		c.JSON(http.StatusOK, reservation)
+	}
+}
+
+// CreateReservation - POST /api/reservations
+func CreateReservation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ReservationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		reservation := models.Reservation{TenantID: *tenantID.(*uint), Status: "booked"}
+		status := http.StatusInternalServerError
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			cfg := loadTenantConfig(tx, reservation.TenantID)
+			if err := markNoShows(tx, reservation.TenantID, cfg.Reservations, time.Now()); err != nil {
+				return err
+			}
+			code, err := req.apply(tx, cfg, &reservation)
+			if err != nil {
+				status = code
+				return err
+			}
+			return tx.Create(&reservation).Error
+		}); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, reservation)
+	}
+}
+
+// UpdateReservation - PUT /api/reservations/:id
+// Only bookings not yet seated can be changed.
+func UpdateReservation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reservation, ok := loadTenantReservation(c, db)
+		if !ok {
+			return
+		}
+		if isNoShow(reservation, loadTenantConfig(db, reservation.TenantID).Reservations, time.Now()) {
+			reservation.Status = "no_show"
+		}
+		if reservation.Status != "booked" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Reservation is " + reservation.Status})
+			return
+		}
+
+		var req ReservationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		status := http.StatusInternalServerError
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			cfg := loadTenantConfig(tx, reservation.TenantID)
+			if err := markNoShows(tx, reservation.TenantID, cfg.Reservations, time.Now()); err != nil {
+				return err
+			}
+			code, err := req.apply(tx, cfg, &reservation)
+			if err != nil {
+				status = code
+				return err
+			}
+			return tx.Save(&reservation).Error
+		}); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, reservation)
+	}
+}
+
+// ReservationStatusRequest - Request body for seating a party or closing a booking
+type ReservationStatusRequest struct {
+	Status  string `json:"status" binding:"required"` // seated, no_show, cancelled
+	TableID *uint  `json:"table_id"`                  // Table the party sits at, for area bookings
+}
+
+// UpdateReservationStatus - PATCH /api/reservations/:id/status
+// Seating a party occupies its table; a table with another party's open tab
+// cannot be given to it.
+func UpdateReservationStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ReservationStatusRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		reservation, ok := loadTenantReservation(c, db)
+		if !ok {
+			return
+		}
+
+		// Close lapsed bookings so they stop holding tables; this one may be among them
+		if err := markNoShows(db, reservation.TenantID, loadTenantConfig(db, reservation.TenantID).Reservations, time.Now()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := db.First(&reservation, reservation.ID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+			return
+		}
+
+		// Late parties marked as no-shows can still be seated
+		switch {
+		case req.Status != "seated" && req.Status != "no_show" && req.Status != "cancelled":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be seated, no_show or cancelled"})
+			return
+		case reservation.Status == "booked",
+			reservation.Status == "no_show" && req.Status == "seated":
+		default:
+			c.JSON(http.StatusConflict, gin.H{"error": "Reservation is " + reservation.Status})
+			return
+		}
+
+		status := http.StatusBadRequest
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			from := reservation.Status
+			reservation.Status = req.Status
+			if req.Status == "seated" {
+				if req.TableID != nil {
+					reservation.TableID = req.TableID
+				}
+				if reservation.TableID == nil {
+					return errors.New("Select the table the party sits at")
+				}
+				if code, err := checkReservation(tx, &reservation); err != nil {
+					status = code
+					return err
+				}
+				if hasOpenOrder(tx, *reservation.TableID) {
+					status = http.StatusConflict
+					return errors.New("Table has an open order")
+				}
+				now := time.Now()
+				reservation.SeatedAt = &now
+				if err := tx.Model(&models.Table{}).Where("id = ?", *reservation.TableID).Update("status", "occupied").Error; err != nil {
+					return err
+				}
+			}
+
+			result := tx.Model(&models.Reservation{}).Where("id = ? AND status = ?", reservation.ID, from).Updates(map[string]interface{}{
+				"status":    reservation.Status,
+				"table_id":  reservation.TableID,
+				"area_id":   reservation.AreaID,
+				"seated_at": reservation.SeatedAt,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				status = http.StatusConflict
+				return errors.New("Reservation has changed; reload it")
+			}
+			return nil
+		}); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, reservation)
+	}
+}
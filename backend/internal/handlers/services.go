@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loadTenantService fetches the service in the URL with its product and checks tenant access
+func loadTenantService(c *gin.Context, db *gorm.DB) (models.ServiceDefinition, bool) {
+	var service models.ServiceDefinition
+	if err := db.Preload("Product").First(&service, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return service, false
+	}
+
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil || service.TenantID != *tenantID.(*uint) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return service, false
+		}
+	}
+
+	return service, true
+}
+
+// ServiceRequest - Request body for creating or updating a service
+type ServiceRequest struct {
+	Name        string       `json:"name" binding:"required"`
+	Price       money.Amount `json:"price" binding:"gte=0"`
+	Duration    int          `json:"duration" binding:"required,gt=0,lte=1440"` // Minutes
+	Category    string       `json:"category"`                                  // Defaults to Services
+	TaxCategory string       `json:"tax_category"`
+	Active      *bool        `json:"active"` // Defaults to true
+}
+
+// apply copies the request onto a service and its product
+func (req ServiceRequest) apply(service *models.ServiceDefinition) {
+	if req.Category == "" {
+		req.Category = "Services"
+	}
+	service.Product.TenantID = service.TenantID
+	service.Product.Name = req.Name
+	service.Product.Price = req.Price
+	service.Product.Category = req.Category
+	service.Product.TaxCategory = req.TaxCategory
+	service.Duration = req.Duration
+	service.Active = req.Active == nil || *req.Active
+}
+
+// GetServices - GET /api/services?active=true
+func GetServices(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		query := db.Where("tenant_id = ?", *tenantID.(*uint)).Preload("Product").Order("id ASC")
+		if c.Query("active") == "true" {
+			query = query.Where("active = ?", true)
+		}
+
+		var services []models.ServiceDefinition
+		if err := query.Find(&services).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, services)
+	}
+}
+
+// CreateService - POST /api/services
+// Also creates the product the service is sold as.
+func CreateService(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ServiceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		service := models.ServiceDefinition{TenantID: *tenantID.(*uint)}
+		req.apply(&service)
+		if err := db.Create(&service).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, service)
+	}
+}
+
+// UpdateService - PUT /api/services/:id
+// Booked appointments keep the times they were booked with.
+func UpdateService(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := loadTenantService(c, db)
+		if !ok {
+			return
+		}
+
+		var req ServiceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.apply(&service)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&service.Product).Error; err != nil {
+				return err
+			}
+			return tx.Omit("Product").Save(&service).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, service)
+	}
+}
+
+// DeleteService - DELETE /api/services/:id
+// A service with appointments still to come cannot be deleted; make it inactive instead.
+func DeleteService(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := loadTenantService(c, db)
+		if !ok {
+			return
+		}
+
+		var count int64
+		db.Model(&models.Appointment{}).Where("service_id = ? AND status = ? AND start_at > ?", service.ID, "booked", time.Now()).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Service has appointments to come"})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("DELETE FROM staff_services WHERE service_id = ?", service.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&service.Product).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&service).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Service deleted"})
+	}
+}
+
+// loadTenantStaff fetches the staff member in the URL with their hours and services and checks tenant access
+func loadTenantStaff(c *gin.Context, db *gorm.DB) (models.Staff, bool) {
+	var staff models.Staff
+	if err := db.Preload("Hours").Preload("Services").First(&staff, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Staff member not found"})
+		return staff, false
+	}
+
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil || staff.TenantID != *tenantID.(*uint) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return staff, false
+		}
+	}
+
+	return staff, true
+}
+
+// StaffHoursRequest - A working day, or a break within one
+type StaffHoursRequest struct {
+	Day       string `json:"day" binding:"required"`        // mon ... sun
+	StartTime string `json:"start_time" binding:"required"` // HH:MM
+	EndTime   string `json:"end_time" binding:"required"`   // HH:MM, after start_time
+	Break     bool   `json:"break"`
+}
+
+// StaffRequest - Request body for creating or updating a staff member. Hours and
+// services replace what the staff member had.
+type StaffRequest struct {
+	Name       string              `json:"name" binding:"required"`
+	Phone      string              `json:"phone"`
+	UserID     *uint               `json:"user_id"`
+	Active     *bool               `json:"active"` // Defaults to true
+	Hours      []StaffHoursRequest `json:"hours" binding:"dive"`
+	ServiceIDs []uint              `json:"service_ids"` // Empty for every service
+}
+
+// apply validates the request and copies it onto a staff member
+func (req StaffRequest) apply(db *gorm.DB, staff *models.Staff) error {
+	if req.UserID != nil {
+		var count int64
+		db.Model(&models.User{}).Where("id = ? AND tenant_id = ?", *req.UserID, staff.TenantID).Count(&count)
+		if count == 0 {
+			return errors.New("User not found")
+		}
+	}
+
+	hours := make([]models.StaffHours, len(req.Hours))
+	for i, h := range req.Hours {
+		day, ok := dayName(h.Day)
+		if !ok {
+			return errors.New("day must be mon, tue, wed, thu, fri, sat or sun")
+		}
+		if !validClock(h.StartTime) || !validClock(h.EndTime) {
+			return errors.New("times must be HH:MM, e.g. 09:00")
+		}
+		if h.EndTime <= h.StartTime {
+			return errors.New("end_time must be after start_time")
+		}
+		hours[i] = models.StaffHours{Day: day, StartTime: h.StartTime, EndTime: h.EndTime, Break: h.Break}
+	}
+
+	var services []models.ServiceDefinition
+	if len(req.ServiceIDs) > 0 {
+		db.Where("id IN ? AND tenant_id = ?", req.ServiceIDs, staff.TenantID).Find(&services)
+		if len(services) != len(req.ServiceIDs) {
+			return errors.New("One or more services were not found")
+		}
+	}
+
+	staff.Name = req.Name
+	staff.Phone = req.Phone
+	staff.UserID = req.UserID
+	staff.Active = req.Active == nil || *req.Active
+	staff.Hours = hours
+	staff.Services = services
+	return nil
+}
+
+// saveStaff writes a staff member with their hours and services
+func saveStaff(tx *gorm.DB, staff *models.Staff) error {
+	if staff.ID != 0 {
+		if err := tx.Where("staff_id = ?", staff.ID).Delete(&models.StaffHours{}).Error; err != nil {
+			return err
+		}
+		for i := range staff.Hours {
+			staff.Hours[i].ID = 0
+		}
+	}
+	if err := tx.Omit("Services").Save(staff).Error; err != nil {
+		return err
+	}
+	return tx.Model(staff).Association("Services").Replace(staff.Services)
+}
+
+// GetStaff - GET /api/staff?service_id=&active=true
+func GetStaff(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		query := db.Where("tenant_id = ?", *tenantID.(*uint)).Preload("Hours").Preload("Services").Order("name ASC")
+		if c.Query("active") == "true" {
+			query = query.Where("active = ?", true)
+		}
+
+		var staff []models.Staff
+		if err := query.Find(&staff).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if serviceID := c.Query("service_id"); serviceID != "" {
+			var service models.ServiceDefinition
+			if err := db.Where("id = ? AND tenant_id = ?", serviceID, *tenantID.(*uint)).First(&service).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+				return
+			}
+			capable := make([]models.Staff, 0, len(staff))
+			for _, s := range staff {
+				if performs(s, service.ID) {
+					capable = append(capable, s)
+				}
+			}
+			staff = capable
+		}
+
+		c.JSON(http.StatusOK, staff)
+	}
+}
+
+// GetStaffMember - GET /api/staff/:id
+func GetStaffMember(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		staff, ok := loadTenantStaff(c, db)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, staff)
+	}
+}
+
+// CreateStaff - POST /api/staff
+func CreateStaff(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req StaffRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		staff := models.Staff{TenantID: *tenantID.(*uint)}
+		if err := req.apply(db, &staff); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return saveStaff(tx, &staff)
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, staff)
+	}
+}
+
+// UpdateStaff - PUT /api/staff/:id
+// Appointments already booked are kept when hours change.
+func UpdateStaff(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		staff, ok := loadTenantStaff(c, db)
+		if !ok {
+			return
+		}
+
+		var req StaffRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := req.apply(db, &staff); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return saveStaff(tx, &staff)
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, staff)
+	}
+}
+
+// DeleteStaff - DELETE /api/staff/:id
+// A staff member with appointments still to come cannot be deleted.
+func DeleteStaff(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		staff, ok := loadTenantStaff(c, db)
+		if !ok {
+			return
+		}
+
+		var count int64
+		db.Model(&models.Appointment{}).Where("staff_id = ? AND status = ? AND start_at > ?", staff.ID, "booked", time.Now()).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": staff.Name + " has appointments to come"})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&staff).Association("Services").Clear(); err != nil {
+				return err
+			}
+			if err := tx.Where("staff_id = ?", staff.ID).Delete(&models.StaffHours{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&staff).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Staff member deleted"})
+	}
+}
+
+// performs reports whether a staff member does a service; staff without a list do every service
+func performs(staff models.Staff, serviceID uint) bool {
+	if len(staff.Services) == 0 {
+		return true
+	}
+	for _, s := range staff.Services {
+		if s.ID == serviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// timeRange is a stretch of time from Start up to End
+type timeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// overlaps reports whether two ranges share any time
+func (r timeRange) overlaps(o timeRange) bool {
+	return r.Start.Before(o.End) && o.Start.Before(r.End)
+}
+
+// workingRanges lists when a staff member works on a day, breaks left out.
+// day is midnight in the tenant's timezone.
+func workingRanges(staff models.Staff, day time.Time) []timeRange {
+	clockOn := func(clock string) time.Time {
+		t, _ := time.Parse("15:04", clock)
+		return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+	}
+
+	var work, breaks []timeRange
+	for _, h := range staff.Hours {
+		if !onDay(h.Day, day.Weekday()) {
+			continue
+		}
+		r := timeRange{clockOn(h.StartTime), clockOn(h.EndTime)}
+		if h.Break {
+			breaks = append(breaks, r)
+		} else {
+			work = append(work, r)
+		}
+	}
+	sort.Slice(work, func(i, j int) bool { return work[i].Start.Before(work[j].Start) })
+
+	// Cut each break out of the working time
+	for _, b := range breaks {
+		var cut []timeRange
+		for _, w := range work {
+			if !w.overlaps(b) {
+				cut = append(cut, w)
+				continue
+			}
+			if w.Start.Before(b.Start) {
+				cut = append(cut, timeRange{w.Start, b.Start})
+			}
+			if b.End.Before(w.End) {
+				cut = append(cut, timeRange{b.End, w.End})
+			}
+		}
+		work = cut
+	}
+	return work
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"ringpos-backend/internal/barcode"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"ringpos-backend/internal/phone"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tenantConfig is the typed view of the settings stored in Tenant.Config
+type tenantConfig struct {
+	ScaleBarcodes []barcode.ScaleLayout `json:"scale_barcodes"`
+	Currency      string                `json:"currency"`      // ISO 4217 code, e.g. IDR
+	CashRounding  *money.Amount         `json:"cash_rounding"` // Cash totals round to a multiple of this; defaults to the currency's
+	Loyalty       loyaltyConfig         `json:"loyalty"`
+	Reservations  reservationConfig     `json:"reservations"`
+	WorkOrders    workOrderConfig       `json:"work_orders"`
+	Appointments  appointmentConfig     `json:"appointments"`
+	// Calling code for customer phones written without one; defaults to Indonesia's 62
+	PhoneCountryCode string `json:"phone_country_code"`
+	// IANA zone for menu availability windows and staff hours, e.g. Asia/Jakarta; defaults to the server's
+	Timezone string `json:"timezone"`
+}
+
+// currency returns the tenant's currency with its cash rounding
+func (cfg tenantConfig) currency() money.Currency {
+	currency, _ := money.LookupCurrency(cfg.Currency)
+	if cfg.CashRounding != nil {
+		currency.CashRounding = *cfg.CashRounding
+	}
+	return currency
+}
+
+// location returns the tenant's timezone
+func (cfg tenantConfig) location() *time.Location {
+	if cfg.Timezone != "" {
+		if loc, err := time.LoadLocation(cfg.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// loadTenantConfig reads a tenant's settings, filling in defaults for anything unset
+func loadTenantConfig(db *gorm.DB, tenantID uint) tenantConfig {
+	var cfg tenantConfig
+
+	var tenant models.Tenant
+	if err := db.Select("id", "config").First(&tenant, tenantID).Error; err == nil && tenant.Config != "" {
+		json.Unmarshal([]byte(tenant.Config), &cfg)
+	}
+
+	if len(cfg.ScaleBarcodes) == 0 {
+		cfg.ScaleBarcodes = barcode.DefaultScaleLayouts
+	}
+	if cfg.Currency == "" {
+		cfg.Currency = money.DefaultCurrency
+	}
+	if cfg.CashRounding == nil {
+		rounding := cfg.currency().CashRounding
+		cfg.CashRounding = &rounding
+	}
+	if cfg.PhoneCountryCode == "" {
+		cfg.PhoneCountryCode = phone.DefaultCountryCode
+	}
+	if cfg.Loyalty.RedeemAs == "" {
+		cfg.Loyalty.RedeemAs = "discount"
+	}
+	cfg.Reservations.applyDefaults()
+	cfg.WorkOrders.applyDefaults()
+	cfg.Appointments.applyDefaults()
+
+	return cfg
+}
+
+// GetSettings - GET /api/settings
+func GetSettings(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tenant not found"})
+			return
+		}
+
+		var tenant models.Tenant
+		if err := db.First(&tenant, *tenantID.(*uint)).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+			return
+		}
+
+		settings := map[string]interface{}{}
+		if tenant.Config != "" {
+			json.Unmarshal([]byte(tenant.Config), &settings)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"settings":  settings,
+			"effective": loadTenantConfig(db, tenant.ID),
+		})
+	}
+}
+
+// UpdateSettings - PUT /api/settings
+// Top-level keys in the body replace the matching keys in Tenant.Config.
+func UpdateSettings(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		if role != "owner" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can change settings"})
+			return
+		}
+
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tenant not found"})
+			return
+		}
+
+		var changes map[string]json.RawMessage
+		if err := c.ShouldBindJSON(&changes); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var tenant models.Tenant
+		if err := db.First(&tenant, *tenantID.(*uint)).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+			return
+		}
+
+		settings := map[string]json.RawMessage{}
+		if tenant.Config != "" {
+			json.Unmarshal([]byte(tenant.Config), &settings)
+		}
+		for k, v := range changes {
+			settings[k] = v
+		}
+
+		// Reject settings that would not parse back into the typed config
+		configJSON, _ := json.Marshal(settings)
+		var cfg tenantConfig
+		if err := json.Unmarshal(configJSON, &cfg); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settings: " + err.Error()})
+			return
+		}
+		if cfg.Currency != "" {
+			if _, ok := money.LookupCurrency(cfg.Currency); !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settings: unsupported currency " + cfg.Currency})
+				return
+			}
+		}
+		if cfg.CashRounding != nil && *cfg.CashRounding < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settings: cash_rounding cannot be negative"})
+			return
+		}
+		if code := cfg.PhoneCountryCode; code != "" {
+			if _, err := strconv.Atoi(code); err != nil || len(code) > 3 || code[0] < '1' || code[0] > '9' {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settings: phone_country_code must be a calling code such as 62"})
+				return
+			}
+		}
+		if cfg.Timezone != "" {
+			if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settings: unknown timezone " + cfg.Timezone})
+				return
+			}
+		}
+		if err := cfg.Loyalty.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settings: " + err.Error()})
+			return
+		}
+		if err := cfg.Reservations.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settings: " + err.Error()})
+			return
+		}
+		if err := cfg.WorkOrders.validate(db, tenant.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settings: " + err.Error()})
+			return
+		}
+		if err := cfg.Appointments.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settings: " + err.Error()})
+			return
+		}
+
+		if err := db.Model(&tenant).Update("config", string(configJSON)).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"settings":  settings,
+			"effective": loadTenantConfig(db, tenant.ID),
+		})
+	}
+}
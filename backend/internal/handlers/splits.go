@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SplitLine - A line, or a share of it, moved to a new bill
+type SplitLine struct {
+	LineID   int     `json:"line_id" binding:"required"`
+	Quantity float64 `json:"quantity" binding:"gte=0"` // Defaults to all that is left of the line
+}
+
+// SplitOrderRequest - Request body for splitting an open tab into several bills
+type SplitOrderRequest struct {
+	Mode  string        `json:"mode" binding:"required"` // items, seat, even
+	Parts int           `json:"parts"`                   // even: number of bills, the original included
+	Bills [][]SplitLine `json:"bills"`                   // items: the lines of each new bill
+}
+
+// splitQty shares a quantity out in parts. The first share, which stays on the
+// original bill, takes what does not divide evenly.
+func splitQty(quantity float64, parts int) (float64, float64, error) {
+	share := roundQty(quantity / float64(parts))
+	if share <= 0 {
+		return 0, 0, errors.New("Quantity is too small to share that many ways")
+	}
+	return roundQty(quantity - share*float64(parts-1)), share, nil
+}
+
+// shareItems splits every line in parts, keeping the first share
+func shareItems(items []OrderItemRequest, parts int) ([]OrderItemRequest, [][]OrderItemRequest, error) {
+	kept := make([]OrderItemRequest, 0, len(items))
+	bills := make([][]OrderItemRequest, parts-1)
+	for _, item := range items {
+		first, share, err := splitQty(item.Quantity, parts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", item.Name, err)
+		}
+		item.Quantity = first
+		kept = append(kept, item)
+		for i := range bills {
+			item.Quantity = share
+			bills[i] = append(bills[i], item)
+		}
+	}
+	return kept, bills, nil
+}
+
+// bills works out which lines stay on the tab and which go to each new bill
+func (req SplitOrderRequest) bills(tab tabDetails) ([]OrderItemRequest, [][]OrderItemRequest, error) {
+	switch req.Mode {
+	case "even":
+		if req.Parts < 2 || req.Parts > 20 {
+			return nil, nil, errors.New("parts must be between 2 and 20")
+		}
+		return shareItems(tab.Items, req.Parts)
+
+	case "seat":
+		// Each seat gets a bill; lines without a seat are shared between them
+		bySeat := map[int][]OrderItemRequest{}
+		var shared []OrderItemRequest
+		for _, item := range tab.Items {
+			if item.Seat == 0 {
+				shared = append(shared, item)
+			} else {
+				bySeat[item.Seat] = append(bySeat[item.Seat], item)
+			}
+		}
+		if len(bySeat) < 2 {
+			return nil, nil, errors.New("Assign lines to at least two seats to split by seat")
+		}
+		seats := make([]int, 0, len(bySeat))
+		for seat := range bySeat {
+			seats = append(seats, seat)
+		}
+		sort.Ints(seats)
+
+		keptShare, sharedBills, err := shareItems(shared, len(seats))
+		if err != nil {
+			return nil, nil, err
+		}
+		kept := append(bySeat[seats[0]], keptShare...)
+		bills := make([][]OrderItemRequest, len(seats)-1)
+		for i, seat := range seats[1:] {
+			bills[i] = append(bySeat[seat], sharedBills[i]...)
+		}
+		return kept, bills, nil
+
+	case "items":
+		if len(req.Bills) == 0 {
+			return nil, nil, errors.New("List the lines of each new bill")
+		}
+		left := map[int]float64{}
+		lines := map[int]OrderItemRequest{}
+		for _, item := range tab.Items {
+			left[item.LineID] = item.Quantity
+			lines[item.LineID] = item
+		}
+
+		bills := make([][]OrderItemRequest, len(req.Bills))
+		for i, billLines := range req.Bills {
+			if len(billLines) == 0 {
+				return nil, nil, fmt.Errorf("Bill %d has no lines", i+1)
+			}
+			for _, line := range billLines {
+				item, ok := lines[line.LineID]
+				if !ok {
+					return nil, nil, fmt.Errorf("Line %d not found on the tab", line.LineID)
+				}
+				quantity := line.Quantity
+				if quantity == 0 {
+					quantity = left[line.LineID]
+				}
+				if quantity <= 0 || quantity > left[line.LineID] {
+					return nil, nil, fmt.Errorf("Only %g of line %d is left to split", left[line.LineID], line.LineID)
+				}
+				left[line.LineID] = roundQty(left[line.LineID] - quantity)
+				item.Quantity = quantity
+				bills[i] = append(bills[i], item)
+			}
+		}
+
+		var kept []OrderItemRequest
+		for _, item := range tab.Items {
+			if left[item.LineID] > 0 {
+				item.Quantity = left[item.LineID]
+				kept = append(kept, item)
+			}
+		}
+		if len(kept) == 0 {
+			return nil, nil, errors.New("Leave at least one item on the original bill")
+		}
+		return kept, bills, nil
+	}
+	return nil, nil, errors.New("mode must be items, seat or even")
+}
+
+// SplitOrder - POST /api/orders/:id/split
+// Splits an open tab into several bills at the same table, by line, by seat or
+// evenly. Each bill is priced on its own and is settled on its own, which is when
+// its stock is deducted. Kitchen tickets stay with the original order.
+func SplitOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SplitOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		order, ok := loadTenantOrder(c, db)
+		if !ok {
+			return
+		}
+		tab, err := parseTab(order)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		kept, bills, err := req.bills(tab)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		responses := make([]gin.H, 0, len(bills)+1)
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			tab.Items = kept
+			quote, err := updateTab(c, tx, &order, tab)
+			if err != nil {
+				return err
+			}
+			responses = append(responses, tabResponse(order, quote, tab))
+
+			for _, items := range bills {
+				bill := tabDetails{
+					Items:         items,
+					Rounds:        tab.Rounds,
+					TableID:       tab.TableID,
+					TableNumber:   tab.TableNumber,
+					CustomerGroup: tab.CustomerGroup,
+					Outlet:        tab.Outlet,
+					SplitFrom:     &order.ID,
+				}
+				orderReq := bill.request(order.TenantID)
+				quote, err := quoteOrder(c, tx, &orderReq)
+				if err != nil {
+					return err
+				}
+				split := models.Order{
+					TenantID: order.TenantID,
+					TableID:  order.TableID,
+					Status:   "PENDING",
+					Total:    quote.Total,
+					Tax:      quote.Tax,
+					Details:  tabOrderDetails(models.Order{}, quote, orderReq, bill),
+				}
+				if err := tx.Create(&split).Error; err != nil {
+					return err
+				}
+				responses = append(responses, tabResponse(split, quote, bill))
+			}
+			return nil
+		}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"orders": responses})
+	}
+}
+
+// MergeOrdersRequest - Request body for merging open tabs into one
+type MergeOrdersRequest struct {
+	OrderIDs []uint `json:"order_ids" binding:"required,min=1"`
+}
+
+// MergeOrders - POST /api/orders/:id/merge
+// Moves the lines of other open tabs, e.g. of tables pushed together, onto this
+// one. The merged orders are closed as MERGED and their kitchen tickets follow
+// the lines; their tables are freed unless another bill is open at them.
+func MergeOrders(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req MergeOrdersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		order, ok := loadTenantOrder(c, db)
+		if !ok {
+			return
+		}
+		tab, err := parseTab(order)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		var quote orderQuote
+		var tickets []models.KitchenTicket
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			var freed []*uint
+			seen := map[uint]bool{}
+			for _, id := range req.OrderIDs {
+				if id == order.ID {
+					return errors.New("An order cannot be merged into itself")
+				}
+				if seen[id] {
+					return fmt.Errorf("Order %d is listed twice", id)
+				}
+				seen[id] = true
+				var source models.Order
+				if err := tx.Where("id = ? AND tenant_id = ?", id, order.TenantID).First(&source).Error; err != nil {
+					return fmt.Errorf("Order %d not found", id)
+				}
+				sourceTab, err := parseTab(source)
+				if err != nil {
+					return fmt.Errorf("Order %d: %w", id, err)
+				}
+
+				// Lines are numbered on after the tab's own
+				lines := map[int]int{}
+				next := tab.nextLineID()
+				for _, item := range sourceTab.Items {
+					lines[item.LineID] = next
+					item.LineID = next
+					tab.Items = append(tab.Items, item)
+					next++
+				}
+				for _, item := range sourceTab.VoidedItems {
+					lines[item.LineID] = next
+					item.LineID = next
+					tab.VoidedItems = append(tab.VoidedItems, item)
+					next++
+				}
+				tab.Rounds = max(tab.Rounds, sourceTab.Rounds)
+				tab.MergedOrders = append(tab.MergedOrders, source.ID)
+				if tab.CustomerID == nil && sourceTab.CustomerID != nil {
+					tab.CustomerID = sourceTab.CustomerID
+					tab.CustomerName = sourceTab.CustomerName
+					tab.CustomerPhone = sourceTab.CustomerPhone
+				}
+
+				result := tx.Model(&models.Order{}).Where("id = ? AND status = ?", source.ID, "PENDING").Update("status", "MERGED")
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected == 0 {
+					return fmt.Errorf("Order %d has already been settled", id)
+				}
+
+				moved, err := moveKitchenTickets(tx, source.ID, order.ID, tab.TableNumber, lines)
+				if err != nil {
+					return err
+				}
+				tickets = append(tickets, moved...)
+				freed = append(freed, source.TableID)
+			}
+
+			if quote, err = updateTab(c, tx, &order, tab); err != nil {
+				return err
+			}
+			for _, tableID := range freed {
+				if err := freeTable(tx, tableID); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		publishTickets(tickets)
+
+		c.JSON(http.StatusOK, tabResponse(order, quote, tab))
+	}
+}
+
+// MoveOrderRequest - Request body for moving an open tab to another table
+type MoveOrderRequest struct {
+	TableID uint `json:"table_id" binding:"required"`
+}
+
+// MoveOrder - PATCH /api/orders/:id/table
+// Moves an open tab to a free table. The kitchen tickets follow the tab.
+func MoveOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req MoveOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		order, ok := loadTenantOrder(c, db)
+		if !ok {
+			return
+		}
+		tab, err := parseTab(order)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if tab.TableID != nil && *tab.TableID == req.TableID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order is already at this table"})
+			return
+		}
+
+		var quote orderQuote
+		var tickets []models.KitchenTicket
+		status := http.StatusBadRequest
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if hasOpenOrder(tx, req.TableID) {
+				status = http.StatusConflict
+				return errors.New("Table already has an open tab; merge the orders instead")
+			}
+
+			from := order.TableID
+			tab.TableID = &req.TableID
+			if quote, err = updateTab(c, tx, &order, tab); err != nil {
+				return err
+			}
+			tab.TableNumber = quote.Table.Name
+
+			if tickets, err = moveKitchenTickets(tx, order.ID, order.ID, tab.TableNumber, nil); err != nil {
+				return err
+			}
+			return freeTable(tx, from)
+		}); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		publishTickets(tickets)
+
+		c.JSON(http.StatusOK, tabResponse(order, quote, tab))
+	}
+}
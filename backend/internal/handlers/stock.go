@@ -26,8 +26,10 @@ func GetStockLogs(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		// Filter by product
+		// A parent product's history includes its variants
 		if productID := c.Query("product_id"); productID != "" {
-			query = query.Where("product_id = ?", productID)
+			query = query.Where("product_id = ? OR product_id IN (?)", productID,
+				db.Model(&models.Product{}).Select("id").Where("parent_id = ?", productID))
 		}
 
 		// Filter by type
@@ -59,7 +61,9 @@ func GetProductStockHistory(db *gorm.DB) gin.HandlerFunc {
 		productID := c.Param("productId")
 		var logs []models.StockLog
 
-		query := db.Where("product_id = ?", productID).Order("created_at DESC").Limit(50)
+		query := db.Where("product_id = ? OR product_id IN (?)", productID,
+			db.Model(&models.Product{}).Select("id").Where("parent_id = ?", productID)).
+			Order("created_at DESC").Limit(50)
 
 		// Tenant isolation
 		role, _ := c.Get("role")
@@ -81,9 +85,11 @@ func GetProductStockHistory(db *gorm.DB) gin.HandlerFunc {
 
 // StockAdjustmentRequest - Request body for stock adjustment
 type StockAdjustmentRequest struct {
-	ProductID    uint   `json:"product_id" binding:"required"`
-	ChangeAmount int    `json:"change_amount" binding:"required"` // Can be positive or negative
-	Reason       string `json:"reason" binding:"required"`        // Damaged, Expired, Correction, Stock Opname
+	ProductID    uint    `json:"product_id" binding:"required"`
+	ChangeAmount float64 `json:"change_amount" binding:"required"` // Can be positive or negative
+	Reason       string  `json:"reason" binding:"required"`        // Damaged, Expired, Correction, Stock Opname
+	LotID        *uint   `json:"lot_id"`                           // Optional, adjusts a specific lot
+	Unit         string  `json:"unit"`                             // Unit of change_amount, defaults to the base unit
 }
 
 // AdjustStock - POST /api/stock/adjust
@@ -121,15 +127,46 @@ func AdjustStock(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Convert to the base unit stock is kept in
+		unit, err := resolveUnit(db, product, req.Unit)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		change := roundQty(req.ChangeAmount * unit.Factor)
+
 		// Update product stock
-		newStock := product.Stock + req.ChangeAmount
+		newStock := roundQty(product.Stock + change)
 		if newStock < 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Stock cannot be negative"})
 			return
 		}
 
+		tx := db.Begin()
+
+		// Adjust the lot alongside the product when one is given
+		if req.LotID != nil {
+			var lot models.StockLot
+			if err := tx.Where("id = ? AND product_id = ?", *req.LotID, product.ID).First(&lot).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusNotFound, gin.H{"error": "Lot not found for this product"})
+				return
+			}
+			if lot.RemainingQty+change < 0 {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Lot quantity cannot be negative"})
+				return
+			}
+			if err := tx.Model(&lot).Update("remaining_qty", roundQty(lot.RemainingQty+change)).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
 		product.Stock = newStock
-		if err := db.Save(&product).Error; err != nil {
+		if err := tx.Save(&product).Error; err != nil {
+			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -138,18 +175,25 @@ func AdjustStock(db *gorm.DB) gin.HandlerFunc {
 		log := models.StockLog{
 			TenantID:     tenantID,
 			ProductID:    req.ProductID,
-			ChangeAmount: req.ChangeAmount,
+			ChangeAmount: change,
 			Type:         "adjustment",
 			Reason:       req.Reason,
+			LotID:        req.LotID,
+			Unit:         unit.Name,
+			UnitQuantity: req.ChangeAmount,
 			UserID:       userID.(uint),
 			Username:     username.(string),
 		}
 
-		if err := db.Create(&log).Error; err != nil {
+		if err := tx.Create(&log).Error; err != nil {
+			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
+		tx.Commit()
+		publishAvailability(db, product.TenantID, []uint{product.ID})
+
 		c.JSON(http.StatusOK, gin.H{
 			"message":   "Stock adjusted successfully",
 			"new_stock": newStock,
@@ -160,10 +204,13 @@ func AdjustStock(db *gorm.DB) gin.HandlerFunc {
 
 // RestockRequest - Request body for restocking from supplier
 type RestockRequest struct {
-	ProductID  uint   `json:"product_id" binding:"required"`
-	Quantity   int    `json:"quantity" binding:"required,gt=0"`
-	SupplierID *uint  `json:"supplier_id"`
-	Notes      string `json:"notes"`
+	ProductID  uint    `json:"product_id" binding:"required"`
+	Quantity   float64 `json:"quantity" binding:"required,gt=0"`
+	SupplierID *uint   `json:"supplier_id"`
+	Notes      string  `json:"notes"`
+	LotNumber  string  `json:"lot_number"`  // Optional batch/lot number
+	ExpiryDate string  `json:"expiry_date"` // Optional, YYYY-MM-DD
+	Unit       string  `json:"unit"`        // Unit received, e.g. "crate"; defaults to the base unit
 }
 
 // RestockProduct - POST /api/stock/restock
@@ -188,6 +235,12 @@ func RestockProduct(db *gorm.DB) gin.HandlerFunc {
 		userID, _ := c.Get("user_id")
 		username, _ := c.Get("username")
 
+		expiryDate, err := parseDate(req.ExpiryDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// Get product
 		var product models.Product
 		if err := db.First(&product, req.ProductID).Error; err != nil {
@@ -201,38 +254,72 @@ func RestockProduct(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Update stock
-		product.Stock += req.Quantity
-		if err := db.Save(&product).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// Convert the received quantity to the base unit
+		unit, err := resolveUnit(db, product, req.Unit)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		quantity := roundQty(req.Quantity * unit.Factor)
 
-		// Create stock log
 		reason := "Restock"
 		if req.Notes != "" {
 			reason = "Restock: " + req.Notes
 		}
 
-		log := models.StockLog{
-			TenantID:     tenantID,
-			ProductID:    req.ProductID,
-			ChangeAmount: req.Quantity,
-			Type:         "restock",
-			Reason:       reason,
-			ReferenceID:  req.SupplierID,
-			UserID:       userID.(uint),
-			Username:     username.(string),
-		}
+		// Stock, the lot and the log are written together so FEFO and the
+		// stock history always account for what was received
+		var lot *models.StockLot
+		var log models.StockLog
+		err = db.Transaction(func(tx *gorm.DB) error {
+			product.Stock = roundQty(product.Stock + quantity)
+			if err := tx.Save(&product).Error; err != nil {
+				return err
+			}
+
+			// Track the receipt as a lot when a lot number or expiry is given
+			if req.LotNumber != "" || expiryDate != nil {
+				lot = &models.StockLot{
+					TenantID:     product.TenantID,
+					ProductID:    product.ID,
+					LotNumber:    req.LotNumber,
+					ExpiryDate:   expiryDate,
+					ReceivedQty:  quantity,
+					RemainingQty: quantity,
+					SupplierID:   req.SupplierID,
+				}
+				if err := tx.Create(lot).Error; err != nil {
+					return err
+				}
+			}
 
-		if err := db.Create(&log).Error; err != nil {
+			log = models.StockLog{
+				TenantID:     tenantID,
+				ProductID:    req.ProductID,
+				ChangeAmount: quantity,
+				Type:         "restock",
+				Reason:       reason,
+				ReferenceID:  req.SupplierID,
+				Unit:         unit.Name,
+				UnitQuantity: req.Quantity,
+				UserID:       userID.(uint),
+				Username:     username.(string),
+			}
+			if lot != nil {
+				log.LotID = &lot.ID
+			}
+			return tx.Create(&log).Error
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		publishAvailability(db, product.TenantID, []uint{product.ID})
 
 		c.JSON(http.StatusOK, gin.H{
 			"message":   "Product restocked successfully",
 			"new_stock": product.Stock,
+			"lot":       lot,
 			"log":       log,
 		})
 	}
@@ -235,6 +235,7 @@ func GetSuperadminStats(db *gorm.DB) gin.HandlerFunc {
 func generateTokenForUser(user models.User) (string, error) {
 	claims := middleware.Claims{
 		UserID:   user.ID,
+		Username: user.Username,
 		TenantID: user.TenantID,
 		Role:     user.Role,
 	}
@@ -0,0 +1,480 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// openOrderStatuses are the order statuses of a tab that is still running at a table
+var openOrderStatuses = []string{"PENDING"}
+
+// tableStatuses are the statuses staff can put a table in
+var tableStatuses = map[string]bool{"available": true, "occupied": true, "reserved": true, "cleaning": true}
+
+// AreaRequest - Request body for creating or updating a floor plan area
+type AreaRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Sequence int    `json:"sequence"`
+}
+
+// TableRequest - Request body for creating or updating a table
+type TableRequest struct {
+	AreaID   uint    `json:"area_id" binding:"required"`
+	Name     string  `json:"name" binding:"required"`
+	Seats    int     `json:"seats" binding:"gte=0"`
+	Shape    string  `json:"shape"` // square (default), round, rectangle
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width" binding:"gte=0"`
+	Height   float64 `json:"height" binding:"gte=0"`
+	Rotation float64 `json:"rotation"`
+}
+
+// apply validates the request and copies it onto a table of tenantID
+func (req TableRequest) apply(db *gorm.DB, tenantID uint, table *models.Table) error {
+	var area models.Area
+	if err := db.Where("id = ? AND tenant_id = ?", req.AreaID, tenantID).First(&area).Error; err != nil {
+		return errors.New("Area not found")
+	}
+
+	var count int64
+	db.Model(&models.Table{}).Where("tenant_id = ? AND name = ? AND id <> ?", tenantID, req.Name, table.ID).Count(&count)
+	if count > 0 {
+		return errors.New("A table named " + req.Name + " already exists")
+	}
+
+	switch req.Shape {
+	case "":
+		req.Shape = "square"
+	case "square", "round", "rectangle":
+	default:
+		return errors.New("shape must be square, round or rectangle")
+	}
+
+	table.AreaID = req.AreaID
+	table.Name = req.Name
+	table.Seats = req.Seats
+	table.Shape = req.Shape
+	table.X = req.X
+	table.Y = req.Y
+	table.Width = req.Width
+	table.Height = req.Height
+	table.Rotation = req.Rotation
+	return nil
+}
+
+// FloorTable - A table on the floor plan with the tab running at it and its next booking
+type FloorTable struct {
+	models.Table
+	CurrentOrderID    *uint               `json:"current_order_id"`
+	CurrentOrderTotal money.Amount        `json:"current_order_total"`
+	OccupiedSince     *time.Time          `json:"occupied_since"`
+	NextReservation   *models.Reservation `json:"next_reservation"`
+}
+
+// FloorArea - An area of the floor plan with its tables
+type FloorArea struct {
+	models.Area
+	Tables []FloorTable `json:"tables"`
+}
+
+// floorTables adds the open orders and bookings to tables. A table with an open
+// order is occupied whatever staff last set; an available table is reserved from
+// the tenant's hold time before its next booking; otherwise it keeps its own status.
+func floorTables(db *gorm.DB, tables []models.Table) []FloorTable {
+	now := time.Now()
+	ids := make([]uint, len(tables))
+	for i, table := range tables {
+		ids[i] = table.ID
+	}
+	var orders []models.Order
+	db.Where("table_id IN ? AND status IN ?", ids, openOrderStatuses).Order("id ASC").Find(&orders)
+
+	open := map[uint]models.Order{}
+	for _, order := range orders {
+		if _, seen := open[*order.TableID]; !seen {
+			open[*order.TableID] = order
+		}
+	}
+
+	booked := upcomingReservations(db, ids, now)
+	configs := map[uint]reservationConfig{}
+
+	floor := make([]FloorTable, len(tables))
+	for i, table := range tables {
+		floor[i] = FloorTable{Table: table}
+		if floor[i].Status == "" {
+			floor[i].Status = "available"
+		}
+		if r, ok := booked[table.ID]; ok {
+			cfg, loaded := configs[table.TenantID]
+			if !loaded {
+				cfg = loadTenantConfig(db, table.TenantID).Reservations
+				configs[table.TenantID] = cfg
+			}
+			floor[i].NextReservation = &r
+			if floor[i].Status == "available" && !now.Before(r.ReservedAt.Add(-time.Duration(cfg.HoldMinutes)*time.Minute)) {
+				floor[i].Status = "reserved"
+			}
+		}
+		if order, ok := open[table.ID]; ok {
+			floor[i].Status = "occupied"
+			floor[i].CurrentOrderID = &order.ID
+			floor[i].CurrentOrderTotal = order.Total
+			floor[i].OccupiedSince = &order.CreatedAt
+		}
+	}
+	return floor
+}
+
+// hasOpenOrder reports whether a tab is still running at the table
+func hasOpenOrder(db *gorm.DB, tableID uint) bool {
+	var count int64
+	db.Model(&models.Order{}).Where("table_id = ? AND status IN ?", tableID, openOrderStatuses).Count(&count)
+	return count > 0
+}
+
+// freeTable marks a table available once it has no open orders left
+func freeTable(tx *gorm.DB, tableID *uint) error {
+	if tableID == nil || hasOpenOrder(tx, *tableID) {
+		return nil
+	}
+	return tx.Model(&models.Table{}).Where("id = ?", *tableID).Update("status", "available").Error
+}
+
+// loadTenantArea fetches the area in the URL and checks tenant access
+func loadTenantArea(c *gin.Context, db *gorm.DB) (models.Area, bool) {
+	var area models.Area
+	if err := db.First(&area, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Area not found"})
+		return area, false
+	}
+
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil || area.TenantID != *tenantID.(*uint) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return area, false
+		}
+	}
+
+	return area, true
+}
+
+// loadTenantTable fetches the table in the URL and checks tenant access
+func loadTenantTable(c *gin.Context, db *gorm.DB) (models.Table, bool) {
+	var table models.Table
+	if err := db.First(&table, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+		return table, false
+	}
+
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil || table.TenantID != *tenantID.(*uint) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return table, false
+		}
+	}
+
+	return table, true
+}
+
+// GetFloorPlan - GET /api/floor-plan
+// Areas in display order, each with its tables and their live status.
+func GetFloorPlan(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		var areas []models.Area
+		if err := db.Where("tenant_id = ?", *tenantID.(*uint)).Order("sequence ASC, id ASC").Find(&areas).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var tables []models.Table
+		if err := db.Where("tenant_id = ?", *tenantID.(*uint)).Order("name ASC").Find(&tables).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		byArea := map[uint][]FloorTable{}
+		for _, table := range floorTables(db, tables) {
+			byArea[table.AreaID] = append(byArea[table.AreaID], table)
+		}
+		plan := make([]FloorArea, len(areas))
+		for i, area := range areas {
+			plan[i] = FloorArea{Area: area, Tables: byArea[area.ID]}
+			if plan[i].Tables == nil {
+				plan[i].Tables = []FloorTable{}
+			}
+		}
+
+		c.JSON(http.StatusOK, plan)
+	}
+}
+
+// GetAreas - GET /api/areas
+func GetAreas(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var areas []models.Area
+
+		query := db.Order("sequence ASC, id ASC")
+
+		// Tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if exists && tenantID != nil {
+				query = query.Where("tenant_id = ?", *tenantID.(*uint))
+			}
+		}
+
+		if err := query.Find(&areas).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, areas)
+	}
+}
+
+// CreateArea - POST /api/areas
+func CreateArea(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req AreaRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		area := models.Area{TenantID: *tenantID.(*uint), Name: req.Name, Sequence: req.Sequence}
+		if err := db.Create(&area).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create area"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, area)
+	}
+}
+
+// UpdateArea - PUT /api/areas/:id
+func UpdateArea(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		area, ok := loadTenantArea(c, db)
+		if !ok {
+			return
+		}
+
+		var req AreaRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		area.Name = req.Name
+		area.Sequence = req.Sequence
+		if err := db.Save(&area).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, area)
+	}
+}
+
+// DeleteArea - DELETE /api/areas/:id
+// Only an empty area can be deleted; move or delete its tables first.
+func DeleteArea(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		area, ok := loadTenantArea(c, db)
+		if !ok {
+			return
+		}
+
+		var count int64
+		db.Model(&models.Table{}).Where("area_id = ?", area.ID).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Area still has tables"})
+			return
+		}
+
+		if err := db.Delete(&area).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Area deleted"})
+	}
+}
+
+// GetTables - GET /api/tables?area_id=&status=
+func GetTables(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var tables []models.Table
+
+		query := db.Order("name ASC")
+
+		// Tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if exists && tenantID != nil {
+				query = query.Where("tenant_id = ?", *tenantID.(*uint))
+			}
+		}
+
+		if areaID := c.Query("area_id"); areaID != "" {
+			query = query.Where("area_id = ?", areaID)
+		}
+
+		if err := query.Find(&tables).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Status is filtered after open orders are taken into account
+		floor := floorTables(db, tables)
+		if status := c.Query("status"); status != "" {
+			matching := []FloorTable{}
+			for _, table := range floor {
+				if table.Status == status {
+					matching = append(matching, table)
+				}
+			}
+			floor = matching
+		}
+
+		c.JSON(http.StatusOK, floor)
+	}
+}
+
+// CreateTable - POST /api/tables
+func CreateTable(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TableRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		table := models.Table{TenantID: *tenantID.(*uint), Status: "available"}
+		if err := req.apply(db, table.TenantID, &table); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := db.Create(&table).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create table"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, table)
+	}
+}
+
+// UpdateTable - PUT /api/tables/:id
+// Moves, resizes or renames a table; its status is changed with PATCH /tables/:id/status.
+func UpdateTable(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		table, ok := loadTenantTable(c, db)
+		if !ok {
+			return
+		}
+
+		var req TableRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := req.apply(db, table.TenantID, &table); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := db.Save(&table).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, table)
+	}
+}
+
+// DeleteTable - DELETE /api/tables/:id
+func DeleteTable(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		table, ok := loadTenantTable(c, db)
+		if !ok {
+			return
+		}
+
+		if hasOpenOrder(db, table.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Table has an open order"})
+			return
+		}
+
+		if err := db.Delete(&table).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Table deleted"})
+	}
+}
+
+// UpdateTableStatus - PATCH /api/tables/:id/status
+// A table with an open order stays occupied until the order is settled.
+func UpdateTableStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Status string `json:"status" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !tableStatuses[req.Status] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be available, occupied, reserved or cleaning"})
+			return
+		}
+
+		table, ok := loadTenantTable(c, db)
+		if !ok {
+			return
+		}
+		if req.Status != "occupied" && hasOpenOrder(db, table.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Table has an open order"})
+			return
+		}
+
+		if err := db.Model(&table).Update("status", req.Status).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, floorTables(db, []models.Table{table})[0])
+	}
+}
@@ -0,0 +1,541 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// VoidedItem - A line taken off an open tab
+type VoidedItem struct {
+	OrderItemRequest
+	Reason   string    `json:"reason"`
+	VoidedAt time.Time `json:"voided_at"`
+	VoidedBy string    `json:"voided_by"`
+}
+
+// tabDetails is what an open order keeps in its details to be quoted again
+type tabDetails struct {
+	Items         []OrderItemRequest `json:"items"`
+	VoidedItems   []VoidedItem       `json:"voided_items"`
+	Rounds        int                `json:"rounds"`
+	TableID       *uint              `json:"table_id"`
+	TableNumber   string             `json:"table_number"`
+	CustomerID    *uint              `json:"customer_id"`
+	CustomerName  string             `json:"customer_name"`
+	CustomerPhone string             `json:"customer_phone"`
+	CustomerGroup string             `json:"customer_group"`
+	Outlet        string             `json:"outlet"`
+	SplitFrom     *uint              `json:"split_from,omitempty"`    // Order this bill was split off
+	MergedOrders  []uint             `json:"merged_orders,omitempty"` // Orders merged into this one
+}
+
+// parseTab reads the tab of an open order
+func parseTab(order models.Order) (tabDetails, error) {
+	var tab tabDetails
+	if order.Status != "PENDING" {
+		return tab, errors.New("Order is not an open tab")
+	}
+	if err := json.Unmarshal([]byte(order.Details), &tab); err != nil {
+		return tab, errors.New("Order details are unreadable")
+	}
+	return tab, nil
+}
+
+// request turns the tab back into an order request for quoting
+func (t tabDetails) request(tenantID uint) CreateOrderRequest {
+	return CreateOrderRequest{
+		TenantID:      tenantID,
+		Items:         t.Items,
+		TableID:       t.TableID,
+		TableNumber:   t.TableNumber,
+		CustomerID:    t.CustomerID,
+		CustomerName:  t.CustomerName,
+		CustomerPhone: t.CustomerPhone,
+		CustomerGroup: t.CustomerGroup,
+		Outlet:        t.Outlet,
+		Open:          true,
+	}
+}
+
+// nextLineID is the line ID for the next item added to the tab
+func (t tabDetails) nextLineID() int {
+	next := 1
+	for _, item := range t.Items {
+		next = max(next, item.LineID+1)
+	}
+	for _, item := range t.VoidedItems {
+		next = max(next, item.LineID+1)
+	}
+	return next
+}
+
+// tabOrderDetails builds the details of an open order, keeping the tab's history
+func tabOrderDetails(order models.Order, quote orderQuote, orderReq CreateOrderRequest, tab tabDetails) string {
+	details := orderDetails(quote, orderReq)
+	details["voided_items"] = tab.VoidedItems
+	details["rounds"] = tab.Rounds
+	if tab.SplitFrom != nil {
+		details["split_from"] = tab.SplitFrom
+	}
+	if len(tab.MergedOrders) > 0 {
+		details["merged_orders"] = tab.MergedOrders
+	}
+	if !order.CreatedAt.IsZero() {
+		details["created_at"] = order.CreatedAt.Format(time.RFC3339)
+	}
+	detailsJSON, _ := json.Marshal(details)
+	return string(detailsJSON)
+}
+
+// tabResponse is what the tab endpoints return for an open order
+func tabResponse(order models.Order, quote orderQuote, tab tabDetails) gin.H {
+	voided := tab.VoidedItems
+	if voided == nil {
+		voided = []VoidedItem{}
+	}
+	return gin.H{
+		"order_id":     order.ID,
+		"order_number": order.ID,
+		"status":       order.Status,
+		"table_id":     order.TableID,
+		"table_number": tab.TableNumber,
+		"rounds":       tab.Rounds,
+		"items":        quote.lines(),
+		"voided_items": voided,
+		"subtotal":     quote.Subtotal,
+		"discounts":    quote.Discounts,
+		"discount":     quote.Discount,
+		"tax":          quote.Tax,
+		"total":        quote.Total,
+		"currency":     quote.Currency,
+	}
+}
+
+// updateTab re-quotes an open order after its items changed and saves it.
+// The update only applies while the order is still open.
+func updateTab(c *gin.Context, tx *gorm.DB, order *models.Order, tab tabDetails) (orderQuote, error) {
+	orderReq := tab.request(order.TenantID)
+	quote, err := quoteOrder(c, tx, &orderReq)
+	if err != nil {
+		return quote, err
+	}
+
+	order.TableID = tab.TableID
+	order.CustomerID = tab.CustomerID
+	order.Total = quote.Total
+	order.Tax = quote.Tax
+	order.Details = tabOrderDetails(*order, quote, orderReq, tab)
+	result := tx.Model(&models.Order{}).Where("id = ? AND status = ?", order.ID, "PENDING").Updates(map[string]interface{}{
+		"table_id":    order.TableID,
+		"customer_id": order.CustomerID,
+		"total":       order.Total,
+		"tax":         order.Tax,
+		"details":     order.Details,
+	})
+	if result.Error != nil {
+		return quote, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return quote, errors.New("Tab has already been settled")
+	}
+	return quote, nil
+}
+
+// loadTenantOrder fetches the order in the URL and checks tenant access
+func loadTenantOrder(c *gin.Context, db *gorm.DB) (models.Order, bool) {
+	var order models.Order
+	if err := db.First(&order, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return order, false
+	}
+
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil || order.TenantID != *tenantID.(*uint) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return order, false
+		}
+	}
+
+	return order, true
+}
+
+// openTab starts a PENDING order at a table with the first round of items.
+// Nothing is paid and no stock is taken until the tab is settled.
+func openTab(c *gin.Context, db *gorm.DB, orderReq *CreateOrderRequest) {
+	if orderReq.TableID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Select a table to open a tab"})
+		return
+	}
+	if orderReq.VoucherCode != "" || orderReq.RedeemPoints > 0 || orderReq.Discount > 0 || len(orderReq.Payments) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Discounts and payments are taken when the tab is settled"})
+		return
+	}
+	for i := range orderReq.Items {
+		orderReq.Items[i].LineID = i + 1
+		orderReq.Items[i].Round = 1
+	}
+
+	tx := db.Begin()
+
+	quote, err := quoteOrder(c, tx, orderReq)
+	if err == nil {
+		err = checkSellable(tx, quote.TenantID, quote.Items)
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if hasOpenOrder(tx, *orderReq.TableID) {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Table already has an open tab"})
+		return
+	}
+
+	tab := tabDetails{Rounds: 1, TableNumber: orderReq.TableNumber}
+	order := models.Order{
+		TenantID:   quote.TenantID,
+		CustomerID: orderReq.CustomerID,
+		TableID:    orderReq.TableID,
+		Status:     "PENDING",
+		Total:      quote.Total,
+		Tax:        quote.Tax,
+		Details:    tabOrderDetails(models.Order{}, quote, *orderReq, tab),
+	}
+	if err := tx.Create(&order).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+		return
+	}
+
+	tickets, err := createKitchenTickets(tx, order, orderReq.TableNumber, 1, quote.Items)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx.Commit()
+	publishTickets(tickets)
+
+	c.JSON(http.StatusCreated, tabResponse(order, quote, tab))
+}
+
+// AddOrderItemsRequest - Request body for a new round on an open tab
+type AddOrderItemsRequest struct {
+	Items []OrderItemRequest `json:"items" binding:"required,min=1"`
+}
+
+// AddOrderItems - POST /api/orders/:id/items
+// Adds a round of items to an open tab and prices the whole tab again.
+func AddOrderItems(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req AddOrderItemsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		order, ok := loadTenantOrder(c, db)
+		if !ok {
+			return
+		}
+		tab, err := parseTab(order)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		tab.Rounds++
+		lineID := tab.nextLineID()
+		for _, item := range req.Items {
+			item.LineID = lineID
+			item.Round = tab.Rounds
+			tab.Items = append(tab.Items, item)
+			lineID++
+		}
+
+		var quote orderQuote
+		var tickets []models.KitchenTicket
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			quote, err = updateTab(c, tx, &order, tab)
+			if err != nil {
+				return err
+			}
+
+			// Only the new round goes to the kitchen
+			var round []pricedItem
+			for _, item := range quote.Items {
+				if item.Round == tab.Rounds {
+					round = append(round, item)
+				}
+			}
+			if err := checkSellable(tx, order.TenantID, round); err != nil {
+				return err
+			}
+			tickets, err = createKitchenTickets(tx, order, tab.TableNumber, tab.Rounds, round)
+			return err
+		}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		publishTickets(tickets)
+
+		c.JSON(http.StatusOK, tabResponse(order, quote, tab))
+	}
+}
+
+// VoidOrderItemRequest - Request body for voiding a line on an open tab
+type VoidOrderItemRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// VoidOrderItem - POST /api/orders/:id/items/:line/void
+// Takes a line off an open tab. The line is kept with the reason for the record.
+func VoidOrderItem(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req VoidOrderItemRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		lineID, err := strconv.Atoi(c.Param("line"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid line"})
+			return
+		}
+
+		order, ok := loadTenantOrder(c, db)
+		if !ok {
+			return
+		}
+		tab, err := parseTab(order)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		kept := make([]OrderItemRequest, 0, len(tab.Items))
+		for _, item := range tab.Items {
+			if item.LineID != lineID {
+				kept = append(kept, item)
+				continue
+			}
+			tab.VoidedItems = append(tab.VoidedItems, VoidedItem{
+				OrderItemRequest: item,
+				Reason:           req.Reason,
+				VoidedAt:         time.Now(),
+				VoidedBy:         c.GetString("username"),
+			})
+		}
+		if len(kept) == len(tab.Items) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Line not found on the tab"})
+			return
+		}
+		tab.Items = kept
+
+		var quote orderQuote
+		var tickets []models.KitchenTicket
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			quote, err = updateTab(c, tx, &order, tab)
+			if err != nil {
+				return err
+			}
+			tickets, err = voidKitchenItems(tx, order.ID, lineID)
+			return err
+		}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		publishTickets(tickets)
+
+		c.JSON(http.StatusOK, tabResponse(order, quote, tab))
+	}
+}
+
+// SettleOrderRequest - Request body for paying an open tab
+type SettleOrderRequest struct {
+	Discount      money.Amount     `json:"discount"`
+	VoucherCode   string           `json:"voucher_code,omitempty"`
+	RedeemPoints  int              `json:"redeem_points,omitempty" binding:"gte=0"`
+	PaymentMethod string           `json:"payment_method"`
+	Payments      []PaymentRequest `json:"payments,omitempty" binding:"dive"`
+	CustomerID    *uint            `json:"customer_id,omitempty"` // Attaches a customer if the tab has none
+}
+
+// SettleOrder - POST /api/orders/:id/settle
+// Prices the tab a last time with the discounts and tenders, marks it PAID,
+// deducts stock and frees the table once no other bill is open at it.
+func SettleOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SettleOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		order, ok := loadTenantOrder(c, db)
+		if !ok {
+			return
+		}
+		tab, err := parseTab(order)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if len(tab.Items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tab has no items; cancel it instead"})
+			return
+		}
+
+		orderReq := tab.request(order.TenantID)
+		orderReq.Open = false
+		orderReq.Discount = req.Discount
+		orderReq.VoucherCode = req.VoucherCode
+		orderReq.RedeemPoints = req.RedeemPoints
+		orderReq.PaymentMethod = req.PaymentMethod
+		orderReq.Payments = req.Payments
+		if orderReq.CustomerID == nil && req.CustomerID != nil {
+			orderReq.CustomerID = req.CustomerID
+			orderReq.CustomerName = ""
+			orderReq.CustomerPhone = ""
+		}
+
+		tx := db.Begin()
+
+		quote, err := quoteOrder(c, tx, &orderReq)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		order.Status = "PAID"
+		order.CustomerID = orderReq.CustomerID
+		order.Total = quote.Total
+		order.Tax = quote.Tax
+		order.Details = tabOrderDetails(order, quote, orderReq, tab)
+		result := tx.Model(&models.Order{}).Where("id = ? AND status = ?", order.ID, "PENDING").Updates(map[string]interface{}{
+			"status":      order.Status,
+			"customer_id": order.CustomerID,
+			"total":       order.Total,
+			"tax":         order.Tax,
+			"details":     order.Details,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Tab has already been settled"})
+			return
+		}
+
+		if status, err := completeOrder(c, tx, quote, order); err != nil {
+			tx.Rollback()
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := freeTable(tx, order.TableID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx.Commit()
+		publishStockOuts(db, quote)
+
+		c.JSON(http.StatusOK, orderResponse(order, quote, "Tab settled"))
+	}
+}
+
+// CancelOrderRequest - Request body for cancelling an open tab
+type CancelOrderRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// CancelOrder - POST /api/orders/:id/cancel
+// Closes an open tab without payment. Nothing was charged or taken from stock, so
+// its lines are voided with the reason, taken off the kitchen tickets and the table freed.
+func CancelOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CancelOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		order, ok := loadTenantOrder(c, db)
+		if !ok {
+			return
+		}
+		tab, err := parseTab(order)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		var details map[string]interface{}
+		if err := json.Unmarshal([]byte(order.Details), &details); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Order details are unreadable"})
+			return
+		}
+		for _, item := range tab.Items {
+			tab.VoidedItems = append(tab.VoidedItems, VoidedItem{
+				OrderItemRequest: item,
+				Reason:           req.Reason,
+				VoidedAt:         time.Now(),
+				VoidedBy:         c.GetString("username"),
+			})
+		}
+		details["items"] = []OrderItemRequest{}
+		details["voided_items"] = tab.VoidedItems
+		detailsJSON, _ := json.Marshal(details)
+
+		var tickets []models.KitchenTicket
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.Order{}).Where("id = ? AND status = ?", order.ID, "PENDING").Updates(map[string]interface{}{
+				"status":  "CANCELLED",
+				"total":   0,
+				"tax":     0,
+				"details": string(detailsJSON),
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("Tab has already been settled")
+			}
+			for _, item := range tab.Items {
+				voided, err := voidKitchenItems(tx, order.ID, item.LineID)
+				if err != nil {
+					return err
+				}
+				tickets = append(tickets, voided...)
+			}
+			return freeTable(tx, order.TableID)
+		}); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		publishTickets(tickets)
+
+		order.Status = "CANCELLED"
+		order.Total = 0
+		order.Tax = 0
+		order.Details = string(detailsJSON)
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "Tab cancelled",
+			"order":        order,
+			"voided_items": tab.VoidedItems,
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TaxLine is one tax or charge on an order, summed over its lines
+type TaxLine struct {
+	TaxRuleID uint         `json:"tax_rule_id"`
+	Name      string       `json:"name"`
+	Rate      float64      `json:"rate"`
+	Inclusive bool         `json:"inclusive"`
+	Base      money.Amount `json:"base"` // Amount the rate was applied to
+	Amount    money.Amount `json:"amount"`
+}
+
+// taxCategory is the category tax rules match a product on. Priced order lines
+// carry their parent's category when a variant has none of its own.
+func taxCategory(product models.Product) string {
+	if product.TaxCategory == "" {
+		return "standard"
+	}
+	return strings.ToLower(product.TaxCategory)
+}
+
+// ruleApplies reports whether a tax rule covers a tax category
+func ruleApplies(rule models.TaxRule, category string) bool {
+	var categories []string
+	json.Unmarshal([]byte(rule.Categories), &categories)
+	if len(categories) == 0 {
+		return true
+	}
+	for _, c := range categories {
+		if strings.EqualFold(c, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTaxes works out a tenant's taxes on discounted order lines, setting each
+// line's Tax, and returns the breakdown with the inclusive and added totals.
+//
+// Rules apply in sequence; a compound rule is charged on the base plus the taxes
+// before it. Every amount is proportional to the pre-tax base, so the base of a
+// line is its net amount divided by 1 plus the share of the inclusive taxes.
+// Each line's taxes are rounded on their own to the currency unit, so the
+// breakdown adds up to the lines.
+func applyTaxes(db *gorm.DB, tenantID uint, items []pricedItem, unit money.Amount) ([]TaxLine, money.Amount, money.Amount) {
+	var rules []models.TaxRule
+	db.Where("tenant_id = ? AND active = ?", tenantID, true).
+		Order("sequence ASC, id ASC").
+		Find(&rules)
+	if len(rules) == 0 {
+		return nil, 0, 0
+	}
+
+	bases := make([]money.Amount, len(rules))
+	amounts := make([]money.Amount, len(rules))
+
+	for i := range items {
+		item := &items[i]
+		item.Tax = 0
+		net := item.Subtotal - item.Discount
+		category := taxCategory(item.Product)
+		if net <= 0 || category == "exempt" {
+			continue
+		}
+
+		// Base and tax of each rule per 1 of pre-tax base
+		ruleBase := make([]float64, len(rules))
+		ruleTax := make([]float64, len(rules))
+		prior, inclusive := 0.0, 0.0
+		for r, rule := range rules {
+			if !ruleApplies(rule, category) {
+				continue
+			}
+			ruleBase[r] = 1
+			if rule.Compound {
+				ruleBase[r] += prior
+			}
+			ruleTax[r] = ruleBase[r] * rule.Rate / 100
+			prior += ruleTax[r]
+			if rule.Inclusive {
+				inclusive += ruleTax[r]
+			}
+		}
+
+		base := float64(net) / (1 + inclusive)
+		for r := range rules {
+			amount := money.Amount(math.Round(ruleTax[r] * base)).RoundTo(unit)
+			bases[r] += money.Amount(math.Round(ruleBase[r] * base))
+			amounts[r] += amount
+			item.Tax += amount
+		}
+	}
+
+	var lines []TaxLine
+	included, added := money.Amount(0), money.Amount(0)
+	for r, rule := range rules {
+		amount := amounts[r]
+		if amount == 0 {
+			continue
+		}
+		lines = append(lines, TaxLine{
+			TaxRuleID: rule.ID,
+			Name:      rule.Name,
+			Rate:      rule.Rate,
+			Inclusive: rule.Inclusive,
+			Base:      bases[r],
+			Amount:    amount,
+		})
+		if rule.Inclusive {
+			included += amount
+		} else {
+			added += amount
+		}
+	}
+
+	return lines, included, added
+}
+
+// TaxRuleRequest - Request body for creating or updating a tax rule
+type TaxRuleRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	Rate       float64  `json:"rate" binding:"gte=0,lte=100"`
+	Inclusive  bool     `json:"inclusive"`
+	Categories []string `json:"categories"` // Product tax categories; empty for all
+	Sequence   int      `json:"sequence"`
+	Compound   bool     `json:"compound"`
+	Active     *bool    `json:"active"` // Defaults to true
+}
+
+// apply copies the request onto a tax rule
+func (req TaxRuleRequest) apply(rule *models.TaxRule) {
+	rule.Name = req.Name
+	rule.Rate = req.Rate
+	rule.Inclusive = req.Inclusive
+	rule.Categories = ""
+	if len(req.Categories) > 0 {
+		b, _ := json.Marshal(req.Categories)
+		rule.Categories = string(b)
+	}
+	rule.Sequence = req.Sequence
+	rule.Compound = req.Compound
+	rule.Active = req.Active == nil || *req.Active
+}
+
+// GetTaxRules - GET /api/tax-rules
+func GetTaxRules(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rules []models.TaxRule
+
+		query := db.Order("sequence ASC, id ASC")
+
+		// Tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if exists && tenantID != nil {
+				query = query.Where("tenant_id = ?", *tenantID.(*uint))
+			}
+		}
+
+		if err := query.Find(&rules).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+// CreateTaxRule - POST /api/tax-rules
+func CreateTaxRule(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TaxRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		rule := models.TaxRule{TenantID: *tenantID.(*uint)}
+		req.apply(&rule)
+		if err := db.Create(&rule).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tax rule"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, rule)
+	}
+}
+
+// UpdateTaxRule - PUT /api/tax-rules/:id
+func UpdateTaxRule(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var rule models.TaxRule
+
+		if err := db.First(&rule, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tax rule not found"})
+			return
+		}
+
+		// Check tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if !exists || tenantID == nil || rule.TenantID != *tenantID.(*uint) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+		}
+
+		var req TaxRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.apply(&rule)
+		if err := db.Save(&rule).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, rule)
+	}
+}
+
+// DeleteTaxRule - DELETE /api/tax-rules/:id
+func DeleteTaxRule(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var rule models.TaxRule
+
+		if err := db.First(&rule, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tax rule not found"})
+			return
+		}
+
+		// Check tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if !exists || tenantID == nil || rule.TenantID != *tenantID.(*uint) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+		}
+
+		if err := db.Delete(&rule).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Tax rule deleted"})
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// baseUnit returns the unit stock is kept in for a product
+func baseUnit(product models.Product) string {
+	if product.Unit == "" {
+		return "pcs"
+	}
+	return product.Unit
+}
+
+// resolveUnit looks up a unit a product is sold in; Factor is how many base units one
+// of it holds. An empty unit or the product's own unit resolves to the base unit with factor 1.
+func resolveUnit(db *gorm.DB, product models.Product, unit string) (models.ProductUnit, error) {
+	unit = strings.TrimSpace(unit)
+	if unit == "" || strings.EqualFold(unit, baseUnit(product)) {
+		return models.ProductUnit{TenantID: product.TenantID, ProductID: product.ID, Name: baseUnit(product), Factor: 1}, nil
+	}
+
+	var productUnit models.ProductUnit
+	if err := db.Where("product_id = ? AND LOWER(name) = ?", product.ID, strings.ToLower(unit)).First(&productUnit).Error; err != nil {
+		return productUnit, errors.New("unknown unit " + unit + " for " + product.Name)
+	}
+	return productUnit, nil
+}
+
+// ProductUnitRequest - Request body for creating or updating a product unit
+type ProductUnitRequest struct {
+	Name   string       `json:"name" binding:"required"`
+	Factor float64      `json:"factor" binding:"required,gt=0"`
+	Price  money.Amount `json:"price"`
+}
+
+// GetProductUnits - GET /api/products/:id/units
+func GetProductUnits(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		var units []models.ProductUnit
+		if err := db.Where("product_id = ?", product.ID).Order("factor ASC").Find(&units).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"base_unit": baseUnit(product),
+			"units":     units,
+		})
+	}
+}
+
+// CreateProductUnit - POST /api/products/:id/units
+func CreateProductUnit(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ProductUnitRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		if strings.EqualFold(req.Name, baseUnit(product)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The base unit does not need a conversion"})
+			return
+		}
+		if _, err := resolveUnit(db, product, req.Name); err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Unit already exists"})
+			return
+		}
+
+		unit := models.ProductUnit{
+			TenantID:  product.TenantID,
+			ProductID: product.ID,
+			Name:      strings.TrimSpace(req.Name),
+			Factor:    req.Factor,
+			Price:     req.Price,
+		}
+		if err := db.Create(&unit).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create unit"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, unit)
+	}
+}
+
+// UpdateProductUnit - PUT /api/products/:id/units/:unitId
+func UpdateProductUnit(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ProductUnitRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		var unit models.ProductUnit
+		if err := db.Where("id = ? AND product_id = ?", c.Param("unitId"), product.ID).First(&unit).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unit not found"})
+			return
+		}
+
+		if strings.EqualFold(req.Name, baseUnit(product)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The base unit does not need a conversion"})
+			return
+		}
+		if existing, err := resolveUnit(db, product, req.Name); err == nil && existing.ID != unit.ID {
+			c.JSON(http.StatusConflict, gin.H{"error": "Unit already exists"})
+			return
+		}
+
+		unit.Name = strings.TrimSpace(req.Name)
+		unit.Factor = req.Factor
+		unit.Price = req.Price
+		if err := db.Save(&unit).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, unit)
+	}
+}
+
+// DeleteProductUnit - DELETE /api/products/:id/units/:unitId
+func DeleteProductUnit(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		product, ok := loadTenantProduct(c, db)
+		if !ok {
+			return
+		}
+
+		if err := db.Where("id = ? AND product_id = ?", c.Param("unitId"), product.ID).Delete(&models.ProductUnit{}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Unit deleted"})
+	}
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// sortedAttributeKeys returns attribute names in a stable order for naming and SKUs
+func sortedAttributeKeys(attrs map[string]string) []string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// variantName builds a display name such as "T-Shirt (Red / M)"
+func variantName(parentName string, attrs map[string]string) string {
+	var values []string
+	for _, k := range sortedAttributeKeys(attrs) {
+		values = append(values, attrs[k])
+	}
+	return parentName + " (" + strings.Join(values, " / ") + ")"
+}
+
+// variantSKU derives a variant SKU from the parent SKU, e.g. TSHIRT-RED-M
+func variantSKU(parentSKU string, attrs map[string]string) string {
+	if parentSKU == "" {
+		return ""
+	}
+	parts := []string{parentSKU}
+	for _, k := range sortedAttributeKeys(attrs) {
+		parts = append(parts, strings.ToUpper(strings.ReplaceAll(attrs[k], " ", "")))
+	}
+	return strings.Join(parts, "-")
+}
+
+// parseVariantAttributes parses the "size=M;color=Red" form used by CSV imports
+func parseVariantAttributes(value string) map[string]string {
+	attrs := map[string]string{}
+	for _, pair := range strings.Split(value, ";") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		k, v := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if k != "" && v != "" {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
+
+func sameAttributes(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// newVariant builds a variant of parent that inherits its catalog fields
+func newVariant(parent models.Product, attrs map[string]string) models.Product {
+	attrsJSON, _ := json.Marshal(attrs)
+	return models.Product{
+		TenantID:          parent.TenantID,
+		Name:              variantName(parent.Name, attrs),
+		Price:             parent.Price,
+		Category:          parent.Category,
+		ImageURL:          parent.ImageURL,
+		SKU:               variantSKU(parent.SKU, attrs),
+		ParentID:          &parent.ID,
+		VariantAttributes: string(attrsJSON),
+	}
+}
+
+// inheritFromParent fills in what a variant leaves to its parent, so a change to
+// the parent carries over to variants that were made before it
+func inheritFromParent(db *gorm.DB, product *models.Product) {
+	if product.ParentID == nil {
+		return
+	}
+	var parent models.Product
+	if err := db.First(&parent, *product.ParentID).Error; err != nil {
+		return
+	}
+	if product.KitchenStation == "" {
+		product.KitchenStation = parent.KitchenStation
+	}
+	if product.TaxCategory == "" {
+		product.TaxCategory = parent.TaxCategory
+	}
+}
+
+// hasVariant reports whether parent already has a variant with these attributes
+func hasVariant(db *gorm.DB, parentID uint, attrs map[string]string) bool {
+	var existing []models.Product
+	db.Where("parent_id = ?", parentID).Find(&existing)
+	for _, v := range existing {
+		var existingAttrs map[string]string
+		if json.Unmarshal([]byte(v.VariantAttributes), &existingAttrs) == nil && sameAttributes(existingAttrs, attrs) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadParentProduct fetches a product that can hold variants and checks tenant access
+func loadParentProduct(c *gin.Context, db *gorm.DB) (models.Product, bool) {
+	parent, ok := loadTenantProduct(c, db)
+	if !ok {
+		return parent, false
+	}
+
+	if parent.ParentID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A variant cannot have variants of its own"})
+		return parent, false
+	}
+
+	return parent, true
+}
+
+// GetProductVariants - GET /api/products/:id/variants
+func GetProductVariants(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parent, ok := loadParentProduct(c, db)
+		if !ok {
+			return
+		}
+
+		var variants []models.Product
+		if err := db.Where("parent_id = ?", parent.ID).Order("id ASC").Find(&variants).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, variants)
+	}
+}
+
+// CreateVariantRequest - Request body for creating a single variant
+type CreateVariantRequest struct {
+	Attributes map[string]string `json:"attributes" binding:"required"`
+	Name       string            `json:"name"` // Defaults to "Parent (value / value)"
+	SKU        string            `json:"sku"`  // Defaults to the parent SKU plus attribute values
+	Barcode    string            `json:"barcode"`
+	Price      money.Amount      `json:"price"` // Defaults to the parent price
+	Stock      float64           `json:"stock"`
+	ImageURL   string            `json:"image_url"`
+}
+
+// CreateProductVariant - POST /api/products/:id/variants
+func CreateProductVariant(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateVariantRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		parent, ok := loadParentProduct(c, db)
+		if !ok {
+			return
+		}
+
+		// Attributes must match the parent's declared options, if any
+		if parent.VariantOptions != "" {
+			var options map[string][]string
+			if err := json.Unmarshal([]byte(parent.VariantOptions), &options); err == nil {
+				for k := range req.Attributes {
+					if _, exists := options[k]; !exists {
+						c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown variant attribute: " + k})
+						return
+					}
+				}
+			}
+		}
+
+		if hasVariant(db, parent.ID, req.Attributes) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Variant already exists"})
+			return
+		}
+
+		variant := newVariant(parent, req.Attributes)
+		if req.Name != "" {
+			variant.Name = req.Name
+		}
+		if req.SKU != "" {
+			variant.SKU = req.SKU
+		}
+		if req.Price > 0 {
+			variant.Price = req.Price
+		}
+		if req.ImageURL != "" {
+			variant.ImageURL = req.ImageURL
+		}
+		variant.Stock = req.Stock
+
+		if skuTaken(db, parent.TenantID, variant.SKU, 0) {
+			c.JSON(http.StatusConflict, gin.H{"error": "SKU already exists"})
+			return
+		}
+
+		if req.Barcode != "" {
+			barcodes, err := buildProductBarcodes(db, parent.TenantID, []string{req.Barcode})
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			variant.Barcodes = barcodes
+		}
+
+		if err := db.Create(&variant).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create variant"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, variant)
+	}
+}
+
+// GenerateProductVariants - POST /api/products/:id/variants/generate
+// Creates one variant for every combination of the parent's variant_options.
+func GenerateProductVariants(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parent, ok := loadParentProduct(c, db)
+		if !ok {
+			return
+		}
+
+		var options map[string][]string
+		if err := json.Unmarshal([]byte(parent.VariantOptions), &options); err != nil || len(options) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Product has no variant_options"})
+			return
+		}
+
+		// Build every combination of option values
+		combinations := []map[string]string{{}}
+		for name, values := range options {
+			var next []map[string]string
+			for _, combo := range combinations {
+				for _, value := range values {
+					attrs := map[string]string{name: value}
+					for k, v := range combo {
+						attrs[k] = v
+					}
+					next = append(next, attrs)
+				}
+			}
+			combinations = next
+		}
+
+		var created []models.Product
+		for _, attrs := range combinations {
+			if hasVariant(db, parent.ID, attrs) {
+				continue
+			}
+			variant := newVariant(parent, attrs)
+			if skuTaken(db, parent.TenantID, variant.SKU, 0) {
+				variant.SKU = ""
+			}
+			if err := db.Create(&variant).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create variant"})
+				return
+			}
+			created = append(created, variant)
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"created":  len(created),
+			"variants": created,
+		})
+	}
+}
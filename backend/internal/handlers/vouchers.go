@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// normalizeVoucherCode makes codes case-insensitive and free of stray spaces
+func normalizeVoucherCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// voucherCodeChars leaves out characters that are easy to misread (0/O, 1/I)
+const voucherCodeChars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// randomVoucherCode returns prefix followed by n random characters
+func randomVoucherCode(prefix string, n int) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	for i := 0; i < n; i++ {
+		idx, _ := rand.Int(rand.Reader, big.NewInt(int64(len(voucherCodeChars))))
+		b.WriteByte(voucherCodeChars[idx.Int64()])
+	}
+	return b.String()
+}
+
+// checkVoucher finds a tenant's voucher and checks it can be used by the customer
+// on an order worth spend. Errors are meant for the cashier.
+func checkVoucher(db *gorm.DB, tenantID uint, code string, customerID *uint, spend money.Amount, now time.Time) (models.Voucher, error) {
+	var voucher models.Voucher
+	if err := db.Where("tenant_id = ? AND code = ?", tenantID, normalizeVoucherCode(code)).First(&voucher).Error; err != nil {
+		return voucher, errors.New("Voucher not found")
+	}
+
+	switch {
+	case !voucher.Active:
+		return voucher, errors.New("Voucher is not active")
+	case voucher.ValidFrom != nil && now.Before(*voucher.ValidFrom):
+		return voucher, errors.New("Voucher is not valid yet")
+	case voucher.ValidUntil != nil && now.After(*voucher.ValidUntil):
+		return voucher, errors.New("Voucher has expired")
+	case voucher.UsageLimit > 0 && voucher.UsedCount >= voucher.UsageLimit:
+		return voucher, errors.New("Voucher has been fully redeemed")
+	case spend < voucher.MinSpend:
+		return voucher, fmt.Errorf("Voucher needs a minimum spend of %s", voucher.MinSpend)
+	}
+
+	if voucher.PerCustomerLimit > 0 {
+		if customerID == nil {
+			return voucher, errors.New("Voucher can only be used by a registered customer")
+		}
+		var used int64
+		db.Model(&models.VoucherRedemption{}).
+			Where("voucher_id = ? AND customer_id = ?", voucher.ID, *customerID).
+			Count(&used)
+		if int(used) >= voucher.PerCustomerLimit {
+			return voucher, errors.New("Voucher has already been used by this customer")
+		}
+	}
+
+	return voucher, nil
+}
+
+// voucherDiscount is what a voucher takes off an order worth amount, with
+// percentages rounded to the currency unit
+func voucherDiscount(voucher models.Voucher, amount money.Amount, unit money.Amount) money.Amount {
+	discount := voucher.Amount
+	if voucher.Type == "percentage" {
+		discount = amount.Percent(voucher.Value).RoundTo(unit)
+		if voucher.MaxDiscount > 0 && discount > voucher.MaxDiscount {
+			discount = voucher.MaxDiscount
+		}
+	}
+	if discount > amount {
+		discount = amount
+	}
+	return discount
+}
+
+// voucherReason describes a voucher for receipts and the preview
+func voucherReason(voucher models.Voucher) string {
+	reason := fmt.Sprintf("%s off", voucher.Amount)
+	if voucher.Type == "percentage" {
+		reason = fmt.Sprintf("%g%% off", voucher.Value)
+		if voucher.MaxDiscount > 0 {
+			reason += fmt.Sprintf(" up to %s", voucher.MaxDiscount)
+		}
+	}
+	if voucher.Campaign != "" {
+		reason += " (" + voucher.Campaign + ")"
+	}
+	return reason
+}
+
+// redeemVoucher uses up one redemption of a voucher inside the order transaction.
+// The usage limits are checked in the same UPDATE that counts the use, so two
+// orders racing for the last use cannot both get it.
+func redeemVoucher(tx *gorm.DB, voucher models.Voucher, orderID uint, customerID *uint, amount money.Amount) error {
+	query := tx.Model(&models.Voucher{}).
+		Where("id = ? AND active = ?", voucher.ID, true).
+		Where("usage_limit = 0 OR used_count < usage_limit")
+	if customerID != nil {
+		query = query.Where("per_customer_limit = 0 OR per_customer_limit > (?)",
+			tx.Model(&models.VoucherRedemption{}).Select("COUNT(*)").
+				Where("voucher_id = ? AND customer_id = ?", voucher.ID, *customerID))
+	}
+
+	result := query.Update("used_count", gorm.Expr("used_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Voucher has been fully redeemed")
+	}
+
+	return tx.Create(&models.VoucherRedemption{
+		TenantID:   voucher.TenantID,
+		VoucherID:  voucher.ID,
+		OrderID:    orderID,
+		CustomerID: customerID,
+		Amount:     amount,
+	}).Error
+}
+
+// VoucherRequest - Request body for creating or updating vouchers.
+// Leave code empty and set count to generate a batch of random codes for a campaign.
+type VoucherRequest struct {
+	Code             string       `json:"code"`
+	Count            int          `json:"count" binding:"gte=0,lte=10000"` // Codes to generate when code is empty
+	Prefix           string       `json:"prefix"`                          // Prefix for generated codes
+	Campaign         string       `json:"campaign"`
+	Type             string       `json:"type" binding:"required,oneof=percentage fixed"`
+	Value            float64      `json:"value" binding:"gte=0"`  // Percent off a percentage voucher
+	Amount           money.Amount `json:"amount" binding:"gte=0"` // Amount off a fixed voucher
+	MaxDiscount      money.Amount `json:"max_discount" binding:"gte=0"`
+	MinSpend         money.Amount `json:"min_spend" binding:"gte=0"`
+	ValidFrom        string       `json:"valid_from"`  // YYYY-MM-DD or RFC3339
+	ValidUntil       string       `json:"valid_until"` // A plain date is valid to the end of that day
+	UsageLimit       int          `json:"usage_limit" binding:"gte=0"`
+	PerCustomerLimit int          `json:"per_customer_limit" binding:"gte=0"`
+	Active           *bool        `json:"active"` // Defaults to true
+}
+
+// apply validates the request and copies everything but the code onto a voucher
+func (req VoucherRequest) apply(v *models.Voucher) error {
+	if req.Type == "percentage" && (req.Value <= 0 || req.Value > 100) {
+		return errors.New("A percentage voucher needs a value between 0 and 100")
+	}
+	if req.Type == "fixed" && req.Amount <= 0 {
+		return errors.New("A fixed voucher needs a positive amount")
+	}
+
+	validFrom, err := parseDate(req.ValidFrom)
+	if err != nil {
+		return err
+	}
+	validUntil, err := parseDate(req.ValidUntil)
+	if err != nil {
+		return err
+	}
+	if validUntil != nil && len(req.ValidUntil) == len("2006-01-02") {
+		end := validUntil.Add(24*time.Hour - time.Second)
+		validUntil = &end
+	}
+
+	v.Campaign = strings.TrimSpace(req.Campaign)
+	v.Type = req.Type
+	v.Value = req.Value
+	v.Amount = req.Amount
+	v.MaxDiscount = req.MaxDiscount
+	v.MinSpend = req.MinSpend
+	v.ValidFrom = validFrom
+	v.ValidUntil = validUntil
+	v.UsageLimit = req.UsageLimit
+	v.PerCustomerLimit = req.PerCustomerLimit
+	v.Active = req.Active == nil || *req.Active
+	return nil
+}
+
+// GetVouchers - GET /api/vouchers
+func GetVouchers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var vouchers []models.Voucher
+
+		query := db.Order("created_at DESC")
+
+		// Tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if exists && tenantID != nil {
+				query = query.Where("tenant_id = ?", *tenantID.(*uint))
+			}
+		}
+
+		if campaign := c.Query("campaign"); campaign != "" {
+			query = query.Where("campaign = ?", campaign)
+		}
+		if code := c.Query("code"); code != "" {
+			query = query.Where("code = ?", normalizeVoucherCode(code))
+		}
+
+		if err := query.Find(&vouchers).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, vouchers)
+	}
+}
+
+// CreateVoucher - POST /api/vouchers
+func CreateVoucher(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req VoucherRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		var template models.Voucher
+		if err := req.apply(&template); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		template.TenantID = *tenantID.(*uint)
+
+		// A single named code
+		if code := normalizeVoucherCode(req.Code); code != "" {
+			var count int64
+			db.Model(&models.Voucher{}).Where("tenant_id = ? AND code = ?", template.TenantID, code).Count(&count)
+			if count > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Voucher code already exists"})
+				return
+			}
+
+			template.Code = code
+			if err := db.Create(&template).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create voucher"})
+				return
+			}
+			c.JSON(http.StatusCreated, template)
+			return
+		}
+
+		if req.Count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a code or the number of codes to generate"})
+			return
+		}
+
+		// A batch of random codes; a clash with an existing code just draws again
+		prefix := normalizeVoucherCode(req.Prefix)
+		vouchers := make([]models.Voucher, 0, req.Count)
+		tx := db.Begin()
+		for len(vouchers) < req.Count {
+			voucher := template
+			voucher.Code = randomVoucherCode(prefix, 8)
+
+			var count int64
+			tx.Model(&models.Voucher{}).Where("tenant_id = ? AND code = ?", voucher.TenantID, voucher.Code).Count(&count)
+			if count > 0 {
+				continue
+			}
+			if err := tx.Create(&voucher).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create vouchers"})
+				return
+			}
+			vouchers = append(vouchers, voucher)
+		}
+		tx.Commit()
+
+		c.JSON(http.StatusCreated, vouchers)
+	}
+}
+
+// UpdateVoucher - PUT /api/vouchers/:id
+// The code and the usage so far cannot be changed.
+func UpdateVoucher(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var voucher models.Voucher
+
+		if err := db.First(&voucher, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Voucher not found"})
+			return
+		}
+
+		// Check tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if !exists || tenantID == nil || voucher.TenantID != *tenantID.(*uint) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+		}
+
+		var req VoucherRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := req.apply(&voucher); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Only touch the editable columns so a redemption in flight keeps its count
+		if err := db.Model(&voucher).Select("campaign", "type", "value", "amount", "max_discount", "min_spend",
+			"valid_from", "valid_until", "usage_limit", "per_customer_limit", "active").
+			Updates(&voucher).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, voucher)
+	}
+}
+
+// DeleteVoucher - DELETE /api/vouchers/:id
+func DeleteVoucher(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var voucher models.Voucher
+
+		if err := db.First(&voucher, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Voucher not found"})
+			return
+		}
+
+		// Check tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if !exists || tenantID == nil || voucher.TenantID != *tenantID.(*uint) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+		}
+
+		if err := db.Delete(&voucher).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Voucher deleted"})
+	}
+}
+
+// GetVoucherRedemptions - GET /api/vouchers/:id/redemptions
+func GetVoucherRedemptions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var voucher models.Voucher
+
+		if err := db.First(&voucher, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Voucher not found"})
+			return
+		}
+
+		// Check tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if !exists || tenantID == nil || voucher.TenantID != *tenantID.(*uint) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+		}
+
+		var redemptions []models.VoucherRedemption
+		if err := db.Where("voucher_id = ?", voucher.ID).Order("created_at DESC").Find(&redemptions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, redemptions)
+	}
+}
+
+// GetVoucherReport - GET /api/vouchers/report
+// Redemptions per campaign, optionally within date_from/date_to.
+func GetVoucherReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		type campaignReport struct {
+			Campaign      string       `json:"campaign"`
+			Vouchers      int64        `json:"vouchers"`
+			Redemptions   int64        `json:"redemptions"`
+			Customers     int64        `json:"customers"`
+			TotalDiscount money.Amount `json:"total_discount"`
+			OrderRevenue  money.Amount `json:"order_revenue"`
+		}
+
+		// Redemptions are counted per campaign from the redemption records
+		redemptions := db.Table("voucher_redemptions").
+			Select("vouchers.campaign AS campaign, COUNT(voucher_redemptions.id) AS redemptions, " +
+				"COUNT(DISTINCT voucher_redemptions.customer_id) AS customers, " +
+				"COALESCE(SUM(voucher_redemptions.amount), 0) AS total_discount, " +
+				"COALESCE(SUM(orders.total), 0) AS order_revenue").
+			Joins("JOIN vouchers ON vouchers.id = voucher_redemptions.voucher_id").
+			Joins("LEFT JOIN orders ON orders.id = voucher_redemptions.order_id").
+			Where("voucher_redemptions.deleted_at IS NULL").
+			Group("vouchers.campaign")
+
+		vouchers := db.Model(&models.Voucher{}).
+			Select("campaign, COUNT(*) AS vouchers").
+			Group("campaign")
+
+		// Tenant isolation
+		role, _ := c.Get("role")
+		if role != "superadmin" {
+			tenantID, exists := c.Get("tenant_id")
+			if exists && tenantID != nil {
+				redemptions = redemptions.Where("voucher_redemptions.tenant_id = ?", *tenantID.(*uint))
+				vouchers = vouchers.Where("tenant_id = ?", *tenantID.(*uint))
+			}
+		}
+
+		if campaign := c.Query("campaign"); campaign != "" {
+			redemptions = redemptions.Where("vouchers.campaign = ?", campaign)
+			vouchers = vouchers.Where("campaign = ?", campaign)
+		}
+		if dateFrom := c.Query("date_from"); dateFrom != "" {
+			redemptions = redemptions.Where("voucher_redemptions.created_at >= ?", dateFrom)
+		}
+		if dateTo := c.Query("date_to"); dateTo != "" {
+			redemptions = redemptions.Where("voucher_redemptions.created_at <= ?", dateTo)
+		}
+
+		var issued []campaignReport
+		if err := vouchers.Scan(&issued).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var redeemed []campaignReport
+		if err := redemptions.Scan(&redeemed).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		byCampaign := map[string]campaignReport{}
+		for _, r := range redeemed {
+			byCampaign[r.Campaign] = r
+		}
+		report := make([]campaignReport, 0, len(issued))
+		for _, r := range issued {
+			row := byCampaign[r.Campaign]
+			row.Campaign = r.Campaign
+			row.Vouchers = r.Vouchers
+			report = append(report, row)
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
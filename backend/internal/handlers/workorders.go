@@ -0,0 +1,599 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"ringpos-backend/internal/models"
+	"ringpos-backend/internal/money"
+	"ringpos-backend/internal/phone"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tenderDeposit is the payment method of a deposit taken at intake and applied at pickup
+const tenderDeposit = "deposit"
+
+// workOrderStage is a column of the work order board
+type workOrderStage struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// workOrderConfig is the work order board in the tenant settings
+type workOrderConfig struct {
+	Stages          []workOrderStage `json:"stages"`           // In board order; the last is ready for pickup
+	TurnaroundHours int              `json:"turnaround_hours"` // Promised ready time when intake gives none; defaults to 24
+}
+
+// defaultWorkOrderStages is the board of a laundry counter
+var defaultWorkOrderStages = []workOrderStage{
+	{Key: "received", Name: "Received"},
+	{Key: "processing", Name: "Processing"},
+	{Key: "ready", Name: "Ready for Pickup"},
+}
+
+// applyDefaults fills in the board left unset
+func (w *workOrderConfig) applyDefaults() {
+	if len(w.Stages) == 0 {
+		w.Stages = defaultWorkOrderStages
+	}
+	if w.TurnaroundHours == 0 {
+		w.TurnaroundHours = 24
+	}
+}
+
+// validate checks the stages and that no open work order is left in a removed one
+func (w workOrderConfig) validate(db *gorm.DB, tenantID uint) error {
+	if w.TurnaroundHours < 0 {
+		return errors.New("turnaround_hours cannot be negative")
+	}
+	w.applyDefaults()
+
+	keys := make([]string, 0, len(w.Stages))
+	seen := map[string]bool{}
+	for _, stage := range w.Stages {
+		if stage.Key == "" || stage.Name == "" {
+			return errors.New("work order stages need a key and a name")
+		}
+		if seen[stage.Key] {
+			return errors.New("work order stage " + stage.Key + " is listed twice")
+		}
+		seen[stage.Key] = true
+		keys = append(keys, stage.Key)
+	}
+
+	var stranded []string
+	db.Model(&models.WorkOrder{}).Where("tenant_id = ? AND status = ? AND stage NOT IN ?", tenantID, "open", keys).
+		Distinct().Pluck("stage", &stranded)
+	if len(stranded) > 0 {
+		return errors.New("work order stage " + stranded[0] + " still has open work orders")
+	}
+	return nil
+}
+
+// hasStage reports whether key is one of the board's stages
+func (w workOrderConfig) hasStage(key string) bool {
+	for _, stage := range w.Stages {
+		if stage.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// readyStage is the stage work orders wait in for pickup
+func (w workOrderConfig) readyStage() string {
+	return w.Stages[len(w.Stages)-1].Key
+}
+
+// workOrderItems reads the service lines of a work order
+func workOrderItems(w models.WorkOrder) ([]OrderItemRequest, error) {
+	var items []OrderItemRequest
+	if err := json.Unmarshal([]byte(w.Items), &items); err != nil {
+		return nil, errors.New("Work order items are unreadable")
+	}
+	return items, nil
+}
+
+// loadTenantWorkOrder fetches the work order in the URL with its photos and checks tenant access
+func loadTenantWorkOrder(c *gin.Context, db *gorm.DB) (models.WorkOrder, bool) {
+	var workOrder models.WorkOrder
+	if err := db.Preload("Photos").First(&workOrder, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work order not found"})
+		return workOrder, false
+	}
+
+	role, _ := c.Get("role")
+	if role != "superadmin" {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil || workOrder.TenantID != *tenantID.(*uint) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return workOrder, false
+		}
+	}
+
+	return workOrder, true
+}
+
+// WorkOrderPhotoRequest - A photo taken at intake
+type WorkOrderPhotoRequest struct {
+	URL     string `json:"url" binding:"required"`
+	Caption string `json:"caption"`
+}
+
+// WorkOrderRequest - Request body for taking in or changing a work order
+type WorkOrderRequest struct {
+	CustomerID    *uint                   `json:"customer_id"`
+	CustomerName  string                  `json:"customer_name"` // Taken from the customer when customer_id is set
+	CustomerPhone string                  `json:"customer_phone"`
+	Items         []OrderItemRequest      `json:"items" binding:"required,min=1"` // The services, e.g. 3.5 kg of Wash & Fold
+	Weight        float64                 `json:"weight" binding:"gte=0"`
+	ItemCount     int                     `json:"item_count" binding:"gte=0"`
+	Notes         string                  `json:"notes"`
+	Photos        []WorkOrderPhotoRequest `json:"photos" binding:"dive"` // Replaces the photos on a change
+	PromisedAt    *time.Time              `json:"promised_at"`           // RFC3339; defaults to the tenant's turnaround
+	Deposit       money.Amount            `json:"deposit" binding:"gte=0"`
+	DepositMethod string                  `json:"deposit_method"`
+}
+
+// apply validates the request, prices the services and copies it onto a work order
+func (req WorkOrderRequest) apply(c *gin.Context, db *gorm.DB, cfg tenantConfig, w *models.WorkOrder) error {
+	orderReq := CreateOrderRequest{
+		TenantID:      w.TenantID,
+		Items:         req.Items,
+		CustomerID:    req.CustomerID,
+		CustomerName:  req.CustomerName,
+		CustomerPhone: req.CustomerPhone,
+		Open:          true,
+	}
+	quote, err := quoteOrder(c, db, &orderReq)
+	if err != nil {
+		return err
+	}
+	if orderReq.CustomerName == "" {
+		return errors.New("customer_name is required")
+	}
+	if orderReq.CustomerPhone != "" {
+		normalized, err := phone.Normalize(orderReq.CustomerPhone, cfg.PhoneCountryCode)
+		if err != nil {
+			return err
+		}
+		orderReq.CustomerPhone = normalized
+	}
+
+	// The deposit is money taken at intake and applied at pickup, so it is taken
+	// once and cannot be a charge or a gift card
+	if w.DepositPaidAt != nil {
+		if req.Deposit != w.Deposit || req.DepositMethod != w.DepositMethod {
+			return errors.New("The deposit has already been taken")
+		}
+	} else {
+		if req.Deposit > 0 && req.DepositMethod == "" {
+			return errors.New("deposit_method is required with a deposit")
+		}
+		if req.DepositMethod == tenderOnAccount || req.DepositMethod == tenderGiftCard || req.DepositMethod == tenderDeposit {
+			return errors.New("A deposit cannot be paid on account, by gift card or from a deposit")
+		}
+		if req.Deposit > quote.Total {
+			return errors.New("The deposit is more than the work order")
+		}
+	}
+
+	items, _ := json.Marshal(quote.lines())
+	w.CustomerID = orderReq.CustomerID
+	w.CustomerName = orderReq.CustomerName
+	w.CustomerPhone = orderReq.CustomerPhone
+	w.Items = string(items)
+	w.Weight = req.Weight
+	w.ItemCount = req.ItemCount
+	w.Notes = req.Notes
+	w.Total = quote.Total
+	w.Deposit = req.Deposit
+	w.DepositMethod = req.DepositMethod
+	if w.Deposit > 0 && w.DepositPaidAt == nil {
+		now := time.Now()
+		w.DepositPaidAt = &now
+	}
+	if req.PromisedAt != nil {
+		w.PromisedAt = req.PromisedAt.UTC()
+	} else if w.PromisedAt.IsZero() {
+		w.PromisedAt = time.Now().UTC().Add(time.Duration(cfg.WorkOrders.TurnaroundHours) * time.Hour)
+	}
+
+	w.Photos = make([]models.WorkOrderPhoto, len(req.Photos))
+	for i, photo := range req.Photos {
+		w.Photos[i] = models.WorkOrderPhoto{URL: photo.URL, Caption: photo.Caption}
+	}
+	return nil
+}
+
+// GetWorkOrders - GET /api/work-orders?status=&stage=&customer_id=&search=
+// Lists open work orders by default, soonest promised first.
+func GetWorkOrders(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		query := db.Where("tenant_id = ? AND status = ?", *tenantID.(*uint), c.DefaultQuery("status", "open")).
+			Preload("Photos").Order("promised_at ASC, id ASC")
+		if stage := c.Query("stage"); stage != "" {
+			query = query.Where("stage = ?", stage)
+		}
+		if customerID := c.Query("customer_id"); customerID != "" {
+			query = query.Where("customer_id = ?", customerID)
+		}
+		if search := c.Query("search"); search != "" {
+			phoneSearch := search
+			if term, ok := phone.SearchTerm(search, loadTenantConfig(db, *tenantID.(*uint)).PhoneCountryCode); ok {
+				phoneSearch = term
+			}
+			query = query.Where("customer_name LIKE ? OR customer_phone LIKE ?", "%"+search+"%", "%"+phoneSearch+"%")
+		}
+
+		var workOrders []models.WorkOrder
+		if err := query.Find(&workOrders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, workOrders)
+	}
+}
+
+// WorkOrderColumn - A stage of the work order board with its open work orders
+type WorkOrderColumn struct {
+	Stage      string             `json:"stage"`
+	Name       string             `json:"name"`
+	Overdue    int                `json:"overdue"` // Past their promised time and not yet ready
+	WorkOrders []models.WorkOrder `json:"work_orders"`
+}
+
+// GetWorkOrderBoard - GET /api/work-orders/board
+// The open work orders in the tenant's stages, soonest promised first.
+func GetWorkOrderBoard(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		var workOrders []models.WorkOrder
+		if err := db.Where("tenant_id = ? AND status = ?", *tenantID.(*uint), "open").
+			Preload("Photos").Order("promised_at ASC, id ASC").Find(&workOrders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		cfg := loadTenantConfig(db, *tenantID.(*uint)).WorkOrders
+		columns := make([]WorkOrderColumn, len(cfg.Stages))
+		index := map[string]int{}
+		for i, stage := range cfg.Stages {
+			columns[i] = WorkOrderColumn{Stage: stage.Key, Name: stage.Name, WorkOrders: []models.WorkOrder{}}
+			index[stage.Key] = i
+		}
+		now := time.Now()
+		for _, w := range workOrders {
+			i := index[w.Stage]
+			columns[i].WorkOrders = append(columns[i].WorkOrders, w)
+			if w.Stage != cfg.readyStage() && w.PromisedAt.Before(now) {
+				columns[i].Overdue++
+			}
+		}
+
+		c.JSON(http.StatusOK, columns)
+	}
+}
+
+// GetWorkOrder - GET /api/work-orders/:id
+func GetWorkOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		workOrder, ok := loadTenantWorkOrder(c, db)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, workOrder)
+	}
+}
+
+// CreateWorkOrder - POST /api/work-orders
+// Takes in a job at the first stage of the board.
+func CreateWorkOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req WorkOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tenantID, exists := c.Get("tenant_id")
+		if !exists || tenantID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tenant ID required"})
+			return
+		}
+
+		cfg := loadTenantConfig(db, *tenantID.(*uint))
+		workOrder := models.WorkOrder{TenantID: *tenantID.(*uint), Stage: cfg.WorkOrders.Stages[0].Key, Status: "open"}
+		if err := req.apply(c, db, cfg, &workOrder); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Create(&workOrder).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, workOrder)
+	}
+}
+
+// UpdateWorkOrder - PUT /api/work-orders/:id
+// Changes the intake details of an open work order, e.g. after weighing the load.
+func UpdateWorkOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req WorkOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		workOrder, ok := loadTenantWorkOrder(c, db)
+		if !ok {
+			return
+		}
+		if workOrder.Status != "open" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Work order is " + workOrder.Status})
+			return
+		}
+
+		if err := req.apply(c, db, loadTenantConfig(db, workOrder.TenantID), &workOrder); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("work_order_id = ?", workOrder.ID).Delete(&models.WorkOrderPhoto{}).Error; err != nil {
+				return err
+			}
+			// Photos are saved with the work order
+			return tx.Save(&workOrder).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, workOrder)
+	}
+}
+
+// WorkOrderStageRequest - Request body for moving a work order on the board
+type WorkOrderStageRequest struct {
+	Stage string `json:"stage" binding:"required"`
+}
+
+// UpdateWorkOrderStage - PATCH /api/work-orders/:id/stage
+// Work orders can move to any stage, including back; reaching the last one marks them ready.
+func UpdateWorkOrderStage(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req WorkOrderStageRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		workOrder, ok := loadTenantWorkOrder(c, db)
+		if !ok {
+			return
+		}
+		if workOrder.Status != "open" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Work order is " + workOrder.Status})
+			return
+		}
+
+		cfg := loadTenantConfig(db, workOrder.TenantID).WorkOrders
+		if !cfg.hasStage(req.Stage) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown stage " + req.Stage})
+			return
+		}
+
+		workOrder.Stage = req.Stage
+		workOrder.ReadyAt = nil
+		if req.Stage == cfg.readyStage() {
+			now := time.Now()
+			workOrder.ReadyAt = &now
+		}
+		result := db.Model(&models.WorkOrder{}).Where("id = ? AND status = ?", workOrder.ID, "open").Updates(map[string]interface{}{
+			"stage":    workOrder.Stage,
+			"ready_at": workOrder.ReadyAt,
+		})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Work order has been closed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, workOrder)
+	}
+}
+
+// CancelWorkOrder - POST /api/work-orders/:id/cancel
+// Any deposit is handed back at the counter.
+func CancelWorkOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		workOrder, ok := loadTenantWorkOrder(c, db)
+		if !ok {
+			return
+		}
+
+		result := db.Model(&models.WorkOrder{}).Where("id = ? AND status = ?", workOrder.ID, "open").Updates(map[string]interface{}{
+			"status":         "cancelled",
+			"deposit_refund": workOrder.Deposit,
+		})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Work order is " + workOrder.Status})
+			return
+		}
+		workOrder.Status = "cancelled"
+		workOrder.DepositRefund = workOrder.Deposit
+
+		c.JSON(http.StatusOK, workOrder)
+	}
+}
+
+// PickupWorkOrderRequest - Request body for handing a work order back and taking the balance
+type PickupWorkOrderRequest struct {
+	Discount      money.Amount     `json:"discount"`
+	VoucherCode   string           `json:"voucher_code,omitempty"`
+	RedeemPoints  int              `json:"redeem_points,omitempty" binding:"gte=0"`
+	PaymentMethod string           `json:"payment_method"`                    // Pays the whole balance
+	Payments      []PaymentRequest `json:"payments,omitempty" binding:"dive"` // Split tenders for the balance; replaces payment_method
+}
+
+// PickupWorkOrder - POST /api/work-orders/:id/pickup
+// Prices the services a last time and records the paid order, the deposit
+// counting as one of its tenders. A deposit worth more than the bill pays all of
+// it and the rest is handed back. Only work orders in the last stage can be picked up.
+func PickupWorkOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PickupWorkOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		workOrder, ok := loadTenantWorkOrder(c, db)
+		if !ok {
+			return
+		}
+		if workOrder.Status != "open" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Work order is " + workOrder.Status})
+			return
+		}
+		if workOrder.Stage != loadTenantConfig(db, workOrder.TenantID).WorkOrders.readyStage() {
+			c.JSON(http.StatusConflict, gin.H{"error": "Work order is not ready for pickup"})
+			return
+		}
+		items, err := workOrderItems(workOrder)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		orderReq := CreateOrderRequest{
+			TenantID:      workOrder.TenantID,
+			Items:         items,
+			Discount:      req.Discount,
+			VoucherCode:   req.VoucherCode,
+			RedeemPoints:  req.RedeemPoints,
+			PaymentMethod: req.PaymentMethod,
+			Payments:      req.Payments,
+			CustomerID:    workOrder.CustomerID,
+			CustomerName:  workOrder.CustomerName,
+			CustomerPhone: workOrder.CustomerPhone,
+			Open:          true, // Priced first; the tenders are checked once the balance is known
+		}
+		// The deposit was taken at intake, so it is tendered as a deposit rather
+		// than as new money. A balance paid with payment_method is what is left once priced.
+		payBalance := workOrder.Deposit > 0 && len(req.Payments) == 0
+		if workOrder.Deposit > 0 {
+			deposit := PaymentRequest{Method: tenderDeposit, Amount: workOrder.Deposit}
+			orderReq.Payments = append([]PaymentRequest{deposit}, req.Payments...)
+			if payBalance {
+				orderReq.Payments = append(orderReq.Payments, PaymentRequest{Method: req.PaymentMethod})
+			}
+		}
+
+		tx := db.Begin()
+
+		var refund money.Amount
+		quote, err := quoteOrder(c, tx, &orderReq)
+		if err == nil {
+			if workOrder.Deposit > 0 {
+				applied := min(workOrder.Deposit, quote.AmountDue)
+				refund = workOrder.Deposit - applied
+				orderReq.Payments[0].Amount = applied
+				if payBalance {
+					last := len(orderReq.Payments) - 1
+					orderReq.Payments[last].Amount = quote.AmountDue - applied
+					if orderReq.Payments[last].Amount <= 0 {
+						orderReq.Payments = orderReq.Payments[:last]
+					}
+				}
+				if applied <= 0 {
+					orderReq.Payments = orderReq.Payments[1:]
+				}
+			}
+			orderReq.Open = false
+			err = quotePayments(tx, &quote, &orderReq)
+		}
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		details := orderDetails(quote, orderReq)
+		details["work_order_id"] = workOrder.ID
+		detailsJSON, _ := json.Marshal(details)
+
+		order := models.Order{
+			TenantID:   quote.TenantID,
+			CustomerID: orderReq.CustomerID,
+			Status:     "PAID",
+			Total:      quote.Total,
+			Tax:        quote.Tax,
+			Details:    string(detailsJSON),
+		}
+		if err := tx.Create(&order).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+			return
+		}
+
+		if status, err := completeOrder(c, tx, quote, order); err != nil {
+			tx.Rollback()
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.WorkOrder{}).Where("id = ? AND status = ?", workOrder.ID, "open").Updates(map[string]interface{}{
+			"status":         "picked_up",
+			"order_id":       order.ID,
+			"picked_up_at":   now,
+			"total":          quote.Total,
+			"deposit_refund": refund,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Work order has already been closed"})
+			return
+		}
+
+		tx.Commit()
+		publishStockOuts(db, quote)
+
+		response := orderResponse(order, quote, fmt.Sprintf("Work order #%d picked up", workOrder.ID))
+		response["work_order_id"] = workOrder.ID
+		response["deposit"] = workOrder.Deposit
+		response["deposit_refund"] = refund
+		c.JSON(http.StatusOK, response)
+	}
+}
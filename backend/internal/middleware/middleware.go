@@ -12,6 +12,7 @@ import (
 
 type Claims struct {
 	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
 	TenantID *uint  `json:"tenant_id"`
 	Role     string `json:"role"`
 	jwt.RegisteredClaims
@@ -57,6 +58,7 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 
 		// Set claims in context for handlers to use
 		c.Set("user_id", claims.UserID)
+		c.Set("username", claims.Username)
 		c.Set("tenant_id", claims.TenantID)
 		c.Set("role", claims.Role)
 
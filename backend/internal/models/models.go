@@ -1,6 +1,7 @@
 package models
 
 import (
+	"ringpos-backend/internal/money"
 	"time"
 
 	"gorm.io/gorm"
@@ -37,45 +38,462 @@ type User struct {
 
 type Product struct {
 	gorm.Model
-	TenantID uint    `json:"tenant_id"`
-	Name     string  `json:"name"`
-	Price    float64 `json:"price"`
-	Stock    int     `json:"stock"`
-	Category string  `json:"category"`
-	ImageURL string  `json:"image_url"`
-	Metadata string  `json:"metadata"` // JSON string for flexible fields
+	TenantID uint         `json:"tenant_id"`
+	Name     string       `json:"name"`
+	Price    money.Amount `json:"price"`
+	Stock    float64      `json:"stock"` // In Unit; fractional for goods sold by weight
+	Unit     string       `json:"unit" gorm:"default:pcs"` // pcs, kg, g, l, ...
+	Category string       `json:"category"`
+	ImageURL string       `json:"image_url"`
+	Metadata string       `json:"metadata"` // JSON string for flexible fields
+	SKU      string       `json:"sku" gorm:"index:idx_products_tenant_sku,unique,where:sku <> '' AND deleted_at IS NULL"`
+	PLU      string       `json:"plu"` // Item code used in scale barcodes with embedded weight or price
+
+	// Tax rules match on TaxCategory; empty is "standard" and "exempt" is never taxed
+	TaxCategory string `json:"tax_category"`
+
+	// Kitchen items are sent to their station (bar, grill, pastry, ...); empty for none
+	KitchenStation string `json:"kitchen_station"`
+
+	// An 86'd item is sold out until staff clear it. Items also stop selling outside
+	// their availability windows and when they, or an ingredient, run out of stock.
+	SoldOut      bool                  `json:"sold_out"`
+	Availability []ProductAvailability `json:"availability,omitempty" gorm:"foreignKey:ProductID"`
+
+	Barcodes   []ProductBarcode `json:"barcodes,omitempty" gorm:"foreignKey:ProductID"`
+	Units      []ProductUnit    `json:"units,omitempty" gorm:"foreignKey:ProductID"`
+	PriceTiers []PriceTier      `json:"price_tiers,omitempty" gorm:"foreignKey:ProductID"`
+
+	// Variants are products of their own with a ParentID, so price, stock,
+	// orders and stock logs all work per variant.
+	ParentID          *uint     `json:"parent_id" gorm:"index"`
+	VariantOptions    string    `json:"variant_options"`    // Parent only, JSON e.g. {"size":["S","M","L"]}
+	VariantAttributes string    `json:"variant_attributes"` // Variant only, JSON e.g. {"size":"M"}
+	Variants          []Product `json:"variants,omitempty" gorm:"foreignKey:ParentID"`
+}
+
+// ProductBarcode is one of possibly several barcodes printed on a product
+type ProductBarcode struct {
+	gorm.Model
+	TenantID  uint   `json:"tenant_id" gorm:"index:idx_barcodes_tenant_code,unique,where:deleted_at IS NULL"`
+	ProductID uint   `json:"product_id" gorm:"index"`
+	Code      string `json:"code" gorm:"index:idx_barcodes_tenant_code,unique,where:deleted_at IS NULL"`
+	Type      string `json:"type"` // EAN13, UPCA, EAN8, OTHER
+}
+
+// ProductUnit is a pack or bulk unit a product is bought or sold in, such as a
+// crate of 24 or a 25kg sack. Stock is always kept in the product's base Unit.
+type ProductUnit struct {
+	gorm.Model
+	TenantID  uint         `json:"tenant_id"`
+	ProductID uint         `json:"product_id" gorm:"index"`
+	Name      string       `json:"name"`   // crate, box, sack
+	Factor    float64      `json:"factor"` // Base units in one of this unit, e.g. 24
+	Price     money.Amount `json:"price"`  // Selling price per unit; 0 means Factor × the base price
+}
+
+// ProductAvailability is a window a product is sold in, such as a breakfast menu
+// until 11:00 or weekends only. A product without windows is always on the menu.
+type ProductAvailability struct {
+	gorm.Model
+	TenantID  uint   `json:"tenant_id"`
+	ProductID uint   `json:"product_id" gorm:"index"`
+	Days      string `json:"days"`       // Comma-separated, e.g. "sat,sun"; empty for every day
+	StartTime string `json:"start_time"` // HH:MM in the tenant's timezone; empty from midnight
+	EndTime   string `json:"end_time"`   // HH:MM, exclusive; empty to midnight. Earlier than StartTime runs past midnight
+}
+
+// RecipeLine is an ingredient used up when a menu product is sold, such as the
+// coffee beans, milk and cup in a latte. A product with a recipe has no stock of
+// its own; selling it deducts its ingredients instead.
+type RecipeLine struct {
+	gorm.Model
+	TenantID     uint    `json:"tenant_id"`
+	ProductID    uint    `json:"product_id" gorm:"index"`
+	IngredientID uint    `json:"ingredient_id" gorm:"index"`
+	Ingredient   Product `json:"ingredient" gorm:"foreignKey:IngredientID"`
+	Quantity     float64 `json:"quantity"` // In the ingredient's base unit, per base unit of the product sold
+}
+
+// PriceTier is a quantity break price for a product, optionally limited to a
+// customer group and/or an outlet
+type PriceTier struct {
+	gorm.Model
+	TenantID      uint         `json:"tenant_id"`
+	ProductID     uint         `json:"product_id" gorm:"index"`
+	MinQty        float64      `json:"min_qty"`        // In the product's base unit
+	Price         money.Amount `json:"price"`          // Base unit price once MinQty is reached
+	CustomerGroup string       `json:"customer_group"` // retail, wholesale, member; empty applies to everyone
+	Outlet        string       `json:"outlet"`         // Empty applies to every outlet
+}
+
+// Promotion is a discount applied automatically to orders it matches
+type Promotion struct {
+	gorm.Model
+	TenantID   uint         `json:"tenant_id" gorm:"index"`
+	Name       string       `json:"name"`
+	Type       string       `json:"type"`        // percentage, fixed, buy_x_get_y, bundle
+	Value      float64      `json:"value"`       // Percent off; percent off the free items for buy_x_get_y (0 = free)
+	Amount     money.Amount `json:"amount"`      // fixed: amount off; bundle: bundle price
+	BuyQty     int          `json:"buy_qty"`     // buy_x_get_y: items to buy; bundle: items in the bundle
+	GetQty     int          `json:"get_qty"`     // buy_x_get_y: items given
+	ProductIDs string       `json:"product_ids"` // JSON array of product IDs; with Categories empty, targets everything
+	Categories string       `json:"categories"`  // JSON array of categories
+	MinSpend   money.Amount `json:"min_spend"`   // Order subtotal needed
+	StartsAt   *time.Time   `json:"starts_at"`
+	EndsAt     *time.Time   `json:"ends_at"`
+	Days       string       `json:"days"`       // JSON array of weekdays, 0 = Sunday; empty for every day
+	StartTime  string       `json:"start_time"` // HH:MM daily window, e.g. happy hour
+	EndTime    string       `json:"end_time"`
+	Stackable  bool         `json:"stackable"` // Combines with other promotions
+	Priority   int          `json:"priority"`  // Higher applies first
+	Active     bool         `json:"active"`
+}
+
+// Voucher is a code entered at checkout for a discount, usually one of many in a campaign
+type Voucher struct {
+	gorm.Model
+	TenantID         uint         `json:"tenant_id" gorm:"index:idx_vouchers_tenant_code,unique,where:deleted_at IS NULL"`
+	Code             string       `json:"code" gorm:"index:idx_vouchers_tenant_code,unique,where:deleted_at IS NULL"`
+	Campaign         string       `json:"campaign" gorm:"index"`
+	Type             string       `json:"type"`         // percentage, fixed
+	Value            float64      `json:"value"`        // Percent off
+	Amount           money.Amount `json:"amount"`       // Amount off a fixed voucher
+	MaxDiscount      money.Amount `json:"max_discount"` // Caps a percentage voucher; 0 for no cap
+	MinSpend         money.Amount `json:"min_spend"`    // Order total after promotions needed
+	ValidFrom        *time.Time   `json:"valid_from"`
+	ValidUntil       *time.Time   `json:"valid_until"`
+	UsageLimit       int          `json:"usage_limit"`        // Total redemptions; 0 for unlimited
+	PerCustomerLimit int          `json:"per_customer_limit"` // Redemptions per customer; 0 for unlimited
+	UsedCount        int          `json:"used_count"`
+	Active           bool         `json:"active"`
+}
+
+// VoucherRedemption records a voucher used on an order
+type VoucherRedemption struct {
+	gorm.Model
+	TenantID   uint         `json:"tenant_id"`
+	VoucherID  uint         `json:"voucher_id" gorm:"index"`
+	Voucher    Voucher      `json:"voucher" gorm:"foreignKey:VoucherID"`
+	OrderID    uint         `json:"order_id" gorm:"index"`
+	CustomerID *uint        `json:"customer_id" gorm:"index"`
+	Amount     money.Amount `json:"amount"` // Discount given
+}
+
+// TaxRule is a tax or charge a tenant adds to orders, such as PPN, PB1 or a service charge
+type TaxRule struct {
+	gorm.Model
+	TenantID   uint    `json:"tenant_id" gorm:"index"`
+	Name       string  `json:"name"`
+	Rate       float64 `json:"rate"`       // Percent
+	Inclusive  bool    `json:"inclusive"`  // Already included in product prices
+	Categories string  `json:"categories"` // JSON array of product tax categories; empty applies to all
+	Sequence   int     `json:"sequence"`   // Lower applies first
+	Compound   bool    `json:"compound"`   // Also charged on the taxes before it, e.g. PB1 on the service charge
+	Active     bool    `json:"active"`
 }
 
 type Order struct {
 	gorm.Model
-	TenantID uint    `json:"tenant_id"`
-	Status   string  `json:"status"` // PENDING, PAID, SERVED, COMPLETED
-	Total    float64 `json:"total"`
-	Details  string  `json:"details"` // JSON string for order items
+	TenantID   uint         `json:"tenant_id"`
+	CustomerID *uint        `json:"customer_id" gorm:"index"`
+	TableID    *uint        `json:"table_id" gorm:"index"`
+	Status     string       `json:"status"` // PENDING, PAID, SERVED, COMPLETED, MERGED, CANCELLED
+	Total      money.Amount `json:"total"`
+	Tax        money.Amount `json:"tax"`     // All taxes and charges, including those already in the prices
+	Details    string       `json:"details"` // JSON string for order items
 }
 
-type Customer struct {
+// Area is a part of a restaurant floor, such as Indoor or Terrace
+type Area struct {
 	gorm.Model
-	TenantID uint   `json:"tenant_id"`
+	TenantID uint   `json:"tenant_id" gorm:"index"`
 	Name     string `json:"name"`
-	Phone    string `json:"phone"`
-	Email    string `json:"email"`
-	Address  string `json:"address"`
-	Notes    string `json:"notes"`
+	Sequence int    `json:"sequence"` // Display order on the floor plan
+}
+
+// Table is a dining table placed on the floor plan. Layout is in floor plan
+// units, with X and Y the top left corner.
+type Table struct {
+	gorm.Model
+	TenantID uint    `json:"tenant_id" gorm:"index"`
+	AreaID   uint    `json:"area_id" gorm:"index"`
+	Name     string  `json:"name"` // Shown on orders as the table number
+	Seats    int     `json:"seats"`
+	Shape    string  `json:"shape"` // square, round, rectangle
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Rotation float64 `json:"rotation"` // Degrees clockwise
+	Status   string  `json:"status"`   // available, occupied, reserved, cleaning; an open order always makes it occupied
+}
+
+// Reservation is a booking for a party at a table, or in an area when the table
+// is picked on arrival. The deposit is recorded here; it is taken at the counter.
+type Reservation struct {
+	gorm.Model
+	TenantID      uint         `json:"tenant_id" gorm:"index"`
+	CustomerID    *uint        `json:"customer_id" gorm:"index"`
+	CustomerName  string       `json:"customer_name"`
+	CustomerPhone string       `json:"customer_phone"`
+	PartySize     int          `json:"party_size"`
+	ReservedAt    time.Time    `json:"reserved_at" gorm:"index"`
+	Duration      int          `json:"duration"` // Minutes the table is held for the party
+	TableID       *uint        `json:"table_id" gorm:"index"`
+	AreaID        *uint        `json:"area_id"`
+	Deposit       money.Amount `json:"deposit"`
+	DepositMethod string       `json:"deposit_method"`
+	Status        string       `json:"status"` // booked, seated, no_show, cancelled
+	SeatedAt      *time.Time   `json:"seated_at"`
+	Note          string       `json:"note"`
+}
+
+// ModifierGroup is a choice offered with products, such as Size or Milk
+type ModifierGroup struct {
+	gorm.Model
+	TenantID  uint       `json:"tenant_id" gorm:"index"`
+	Name      string     `json:"name"`
+	MinSelect int        `json:"min_select"` // 0 makes the group optional
+	MaxSelect int        `json:"max_select"` // 0 for no limit; 1 for a single choice
+	Modifiers []Modifier `json:"modifiers" gorm:"foreignKey:GroupID"`
+}
+
+// Modifier is an option of a modifier group
+type Modifier struct {
+	gorm.Model
+	TenantID        uint         `json:"tenant_id"`
+	GroupID         uint         `json:"group_id" gorm:"index"`
+	Name            string       `json:"name"`
+	PriceAdjustment money.Amount `json:"price_adjustment"` // Added to the item price; may be negative
+	StockProductID  *uint        `json:"stock_product_id"` // Ingredient taken from stock when sold, if any
+	StockQuantity   float64      `json:"stock_quantity"`   // Of the ingredient per item, in its base unit
+	Sequence        int          `json:"sequence"`
+}
+
+// ProductModifierGroup offers a modifier group with a product
+type ProductModifierGroup struct {
+	ProductID       uint `json:"product_id" gorm:"primaryKey"`
+	ModifierGroupID uint `json:"modifier_group_id" gorm:"primaryKey;index"`
+	Sequence        int  `json:"sequence"`
+}
+
+// KitchenTicket is what one kitchen station prepares for a round of an order
+type KitchenTicket struct {
+	gorm.Model
+	TenantID    uint                `json:"tenant_id" gorm:"index"`
+	OrderID     uint                `json:"order_id" gorm:"index"`
+	Station     string              `json:"station" gorm:"index"`
+	Round       int                 `json:"round"`
+	TableNumber string              `json:"table_number"`
+	Status      string              `json:"status"` // Least advanced of its items: queued, preparing, ready, served; void when all are
+	Items       []KitchenTicketItem `json:"items" gorm:"foreignKey:TicketID"`
+}
+
+// KitchenTicketItem is an order line on a kitchen ticket
+type KitchenTicketItem struct {
+	gorm.Model
+	TicketID  uint    `json:"ticket_id" gorm:"index"`
+	LineID    int     `json:"line_id"` // Order line the item is for
+	ProductID uint    `json:"product_id"`
+	Name      string  `json:"name"`
+	Quantity  float64 `json:"quantity"`
+	Modifiers string  `json:"modifiers"` // Names of the chosen modifiers, e.g. "Large, Oat milk"
+	Note      string  `json:"note"`
+	Status    string  `json:"status"` // queued, preparing, ready, served, void
+}
+
+// WorkOrder is a job taken in at a service counter, such as a laundry load or a
+// repair. It moves through the tenant's stages and is paid, less the deposit,
+// when the customer picks it up.
+type WorkOrder struct {
+	gorm.Model
+	TenantID      uint             `json:"tenant_id" gorm:"index"`
+	CustomerID    *uint            `json:"customer_id" gorm:"index"`
+	CustomerName  string           `json:"customer_name"`
+	CustomerPhone string           `json:"customer_phone"`
+	Items         string           `json:"items"`      // JSON string of the service lines, priced again at pickup
+	Weight        float64          `json:"weight"`     // kg, for laundry
+	ItemCount     int              `json:"item_count"` // Pieces handed in
+	Notes         string           `json:"notes"`      // Stains, damage, missing buttons, ...
+	Photos        []WorkOrderPhoto `json:"photos" gorm:"foreignKey:WorkOrderID"`
+	Stage         string           `json:"stage" gorm:"index"` // One of the tenant's work order stages
+	Status        string           `json:"status"`             // open, picked_up, cancelled
+	PromisedAt    time.Time        `json:"promised_at" gorm:"index"`
+	ReadyAt       *time.Time       `json:"ready_at"` // When it reached the last stage
+	Total         money.Amount     `json:"total"`    // As priced at intake or the last change
+	Deposit       money.Amount     `json:"deposit"`
+	DepositMethod string           `json:"deposit_method"`
+	DepositPaidAt *time.Time       `json:"deposit_paid_at"` // When the deposit was taken, at intake
+	DepositRefund money.Amount     `json:"deposit_refund"`  // Handed back when the bill came to less, or on cancelling
+	OrderID       *uint            `json:"order_id"`        // Paid order made at pickup
+	PickedUpAt    *time.Time       `json:"picked_up_at"`
+}
+
+// WorkOrderPhoto is a picture taken at intake, e.g. of a stain or a cracked screen
+type WorkOrderPhoto struct {
+	gorm.Model
+	WorkOrderID uint   `json:"work_order_id" gorm:"index"`
+	URL         string `json:"url"`
+	Caption     string `json:"caption"`
+}
+
+// ServiceDefinition is something customers book time for, such as a haircut or a massage.
+// It is sold as its own product, which carries the name, price and taxes.
+type ServiceDefinition struct {
+	gorm.Model
+	TenantID  uint    `json:"tenant_id" gorm:"index"`
+	ProductID uint    `json:"product_id" gorm:"index"`
+	Product   Product `json:"product" gorm:"foreignKey:ProductID"`
+	Duration  int     `json:"duration"` // Minutes
+	Active    bool    `json:"active"`
+}
+
+// Staff is a person who performs services; they need not have a login
+type Staff struct {
+	gorm.Model
+	TenantID uint                `json:"tenant_id" gorm:"index"`
+	Name     string              `json:"name"`
+	Phone    string              `json:"phone"`
+	UserID   *uint               `json:"user_id"` // Their login, if any
+	Active   bool                `json:"active"`
+	Hours    []StaffHours        `json:"hours" gorm:"foreignKey:StaffID"`
+	Services []ServiceDefinition `json:"services" gorm:"many2many:staff_services;joinForeignKey:StaffID;joinReferences:ServiceID"` // What they perform; none for every service
+}
+
+// StaffHours is when a staff member works on a day of the week, or a break
+// within it, such as lunch. Times are HH:MM in the tenant's timezone.
+type StaffHours struct {
+	gorm.Model
+	StaffID   uint   `json:"staff_id" gorm:"index"`
+	Day       string `json:"day"` // mon, tue, wed, thu, fri, sat, sun
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Break     bool   `json:"break"`
+}
+
+// Appointment is a booking of a service with a staff member. Checking in opens
+// an order for it, to which add-on services can be added before it is paid.
+type Appointment struct {
+	gorm.Model
+	TenantID      uint              `json:"tenant_id" gorm:"index"`
+	ServiceID     uint              `json:"service_id"`
+	Service       ServiceDefinition `json:"service" gorm:"foreignKey:ServiceID"`
+	StaffID       uint              `json:"staff_id" gorm:"index"`
+	StaffName     string            `json:"staff_name"` // Denormalized for easy display
+	CustomerID    *uint             `json:"customer_id" gorm:"index"`
+	CustomerName  string            `json:"customer_name"`
+	CustomerPhone string            `json:"customer_phone"`
+	StartAt       time.Time         `json:"start_at" gorm:"index"`
+	EndAt         time.Time         `json:"end_at"`
+	Status        string            `json:"status"` // booked, checked_in, no_show, cancelled
+	CheckedInAt   *time.Time        `json:"checked_in_at"`
+	OrderID       *uint             `json:"order_id"` // Open order made at check-in
+	Note          string            `json:"note"`
+}
+
+type Customer struct {
+	gorm.Model
+	TenantID      uint   `json:"tenant_id"`
+	Name          string `json:"name"`
+	Phone         string `json:"phone"`
+	Email         string `json:"email"`
+	Address       string `json:"address"`
+	Notes         string `json:"notes"`
+	CustomerGroup string `json:"customer_group" gorm:"default:retail"` // retail, wholesale, member; selects price tiers
+	Points        int    `json:"points"`                               // Loyalty point balance, kept in step with the ledger
+	LoyaltyTier   string `json:"loyalty_tier"`                         // Membership tier reached by lifetime spend
+
+	// Buying on account (kasbon). Balance is kept in step with the receivables ledger.
+	CreditLimit money.Amount `json:"credit_limit"` // Most the customer may owe; 0 allows no credit
+	Balance     money.Amount `json:"balance"`      // Owed on account; negative is store credit
+}
+
+// GiftCard is a stored-value card. Its balance is owed to the holder, so
+// selling one is a liability rather than revenue.
+type GiftCard struct {
+	gorm.Model
+	TenantID   uint         `json:"tenant_id" gorm:"index:idx_gift_cards_tenant_code,unique,where:deleted_at IS NULL"`
+	Code       string       `json:"code" gorm:"index:idx_gift_cards_tenant_code,unique,where:deleted_at IS NULL"`
+	CustomerID *uint        `json:"customer_id" gorm:"index"` // Optional holder
+	Balance    money.Amount `json:"balance"`
+	Status     string       `json:"status"` // active, void
+	ExpiresAt  *time.Time   `json:"expires_at"`
+}
+
+// GiftCardEntry is a movement on a gift card's balance
+type GiftCardEntry struct {
+	gorm.Model
+	TenantID      uint         `json:"tenant_id"`
+	GiftCardID    uint         `json:"gift_card_id" gorm:"index"`
+	OrderID       *uint        `json:"order_id" gorm:"index"`
+	Type          string       `json:"type"`           // issue, top_up, redeem, void
+	Amount        money.Amount `json:"amount"`         // Positive when loaded, negative when spent
+	Balance       money.Amount `json:"balance"`        // Balance after the entry
+	PaymentMethod string       `json:"payment_method"` // How an issue or top-up was paid
+	Note          string       `json:"note"`
+	UserID        uint         `json:"user_id"`
+}
+
+// ReceivableEntry is a line in a customer's account: a charge for an order paid
+// on account, or a payment against the balance
+type ReceivableEntry struct {
+	gorm.Model
+	TenantID      uint         `json:"tenant_id"`
+	CustomerID    uint         `json:"customer_id" gorm:"index"`
+	OrderID       *uint        `json:"order_id" gorm:"index"`
+	Type          string       `json:"type"`           // charge, payment
+	Amount        money.Amount `json:"amount"`         // Positive for charges, negative for payments
+	Open          money.Amount `json:"open"`           // Part of a charge still unpaid, for aging
+	PaymentMethod string       `json:"payment_method"` // How a payment was made
+	Note          string       `json:"note"`
+	UserID        uint         `json:"user_id"`
+}
+
+// LoyaltyEntry is a line in a customer's points ledger
+type LoyaltyEntry struct {
+	gorm.Model
+	TenantID   uint       `json:"tenant_id"`
+	CustomerID uint       `json:"customer_id" gorm:"index"`
+	OrderID    *uint      `json:"order_id" gorm:"index"`
+	Type       string     `json:"type"`      // earn, redeem, expire, adjust
+	Points     int        `json:"points"`    // Positive when credited, negative when spent or expired
+	Remaining  int        `json:"remaining"` // Credited points not yet spent or expired
+	ExpiresAt  *time.Time `json:"expires_at"`
+	Note       string     `json:"note"`
+	UserID     uint       `json:"user_id"`
 }
 
 // StockLog tracks all inventory changes
 type StockLog struct {
 	gorm.Model
-	TenantID     uint   `json:"tenant_id"`
-	ProductID    uint   `json:"product_id"`
+	TenantID     uint    `json:"tenant_id"`
+	ProductID    uint    `json:"product_id"`
 	Product      Product `json:"product" gorm:"foreignKey:ProductID"`
-	ChangeAmount int    `json:"change_amount"` // Positive for IN, Negative for OUT
-	Type         string `json:"type"`          // sale, restock, adjustment, return
-	Reason       string `json:"reason"`        // e.g., "Sold", "Damaged", "Expired", "Stock Opname"
-	ReferenceID  *uint  `json:"reference_id"`  // Order ID or other reference
-	UserID       uint   `json:"user_id"`
-	Username     string `json:"username"`      // Denormalized for easy display
+	ChangeAmount float64 `json:"change_amount"` // Positive for IN, Negative for OUT, in the product's unit
+	Type         string  `json:"type"`          // sale, restock, adjustment, return
+	Reason       string  `json:"reason"`        // e.g., "Sold", "Damaged", "Expired", "Stock Opname"
+	ReferenceID  *uint   `json:"reference_id"`  // Order ID or other reference
+	LotID        *uint   `json:"lot_id"`        // Stock lot affected, if lot-tracked
+	Unit         string  `json:"unit"`          // Unit used on the document, e.g. "crate"
+	UnitQuantity float64 `json:"unit_quantity"` // ChangeAmount expressed in Unit
+	Shortfall    float64 `json:"shortfall"`     // Sales only: what could not be taken because stock ran out
+	UserID       uint    `json:"user_id"`
+	Username     string  `json:"username"`      // Denormalized for easy display
+}
+
+// StockLot is a received batch of a product with an optional lot number and expiry date.
+// Sales consume lots first-expired-first-out.
+type StockLot struct {
+	gorm.Model
+	TenantID     uint       `json:"tenant_id" gorm:"index"`
+	ProductID    uint       `json:"product_id" gorm:"index"`
+	Product      Product    `json:"product" gorm:"foreignKey:ProductID"`
+	LotNumber    string     `json:"lot_number"`
+	ExpiryDate   *time.Time `json:"expiry_date"`
+	ReceivedQty  float64    `json:"received_qty"`
+	RemainingQty float64    `json:"remaining_qty"`
+	SupplierID   *uint      `json:"supplier_id"`
 }
 
 // Supplier for managing suppliers
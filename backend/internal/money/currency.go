@@ -0,0 +1,38 @@
+package money
+
+import "strings"
+
+// Currency describes how a currency is shown and settled in cash
+type Currency struct {
+	Code         string `json:"code"`
+	Symbol       string `json:"symbol"`
+	Decimals     int    `json:"decimals"`      // Decimals shown on receipts
+	CashRounding Amount `json:"cash_rounding"` // Smallest step cash totals round to; 0 for none
+}
+
+// Currencies are the currencies a tenant can trade in
+var Currencies = map[string]Currency{
+	"IDR": {Code: "IDR", Symbol: "Rp", Decimals: 0, CashRounding: 10000},
+	"USD": {Code: "USD", Symbol: "$", Decimals: 2},
+	"SGD": {Code: "SGD", Symbol: "S$", Decimals: 2, CashRounding: 5},
+	"MYR": {Code: "MYR", Symbol: "RM", Decimals: 2, CashRounding: 5},
+	"EUR": {Code: "EUR", Symbol: "€", Decimals: 2},
+}
+
+// Unit is the smallest amount the currency is shown in, e.g. 1.00 for IDR and 0.01 for USD
+func (c Currency) Unit() Amount {
+	unit := Amount(1)
+	for d := c.Decimals; d < 2; d++ {
+		unit *= 10
+	}
+	return unit
+}
+
+// DefaultCurrency is used for tenants that have not picked one
+const DefaultCurrency = "IDR"
+
+// LookupCurrency finds a currency by its ISO code, case-insensitively
+func LookupCurrency(code string) (Currency, bool) {
+	c, ok := Currencies[strings.ToUpper(strings.TrimSpace(code))]
+	return c, ok
+}
@@ -0,0 +1,223 @@
+// Package money keeps amounts in integer minor units so totals, discounts and
+// taxes add up exactly instead of drifting with float rounding.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount is a money value in hundredths of the currency unit. Every currency
+// is held with two decimals; IDR amounts simply have no cents.
+// In JSON it is a plain decimal number in the major unit, e.g. 2.5 or 18000.
+type Amount int64
+
+// ErrInvalidAmount is returned for text that is not a decimal amount
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// FromFloat converts a major unit float, rounding to the nearest minor unit.
+// Only use it at the edges (scale labels, legacy data); keep arithmetic in Amount.
+func FromFloat(f float64) Amount {
+	return Amount(math.Round(f * 100))
+}
+
+// Parse reads a decimal amount such as "2.50", "-3" or "18000". Digits past the
+// second decimal are rounded half away from zero.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, ErrInvalidAmount
+	}
+	for _, part := range []string{whole, frac} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return 0, ErrInvalidAmount
+			}
+		}
+	}
+
+	units := int64(0)
+	if whole != "" {
+		n, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil || n > math.MaxInt64/100 {
+			return 0, ErrInvalidAmount
+		}
+		units = n
+	}
+
+	padded := frac + "000"
+	cents, _ := strconv.ParseInt(padded[:2], 10, 64)
+	if padded[2] >= '5' {
+		cents++
+	}
+
+	a := Amount(units*100 + cents)
+	if negative {
+		a = -a
+	}
+	return a, nil
+}
+
+// Float returns the amount in the major unit, for display and ratios
+func (a Amount) Float() float64 {
+	return float64(a) / 100
+}
+
+// String formats the amount with two decimals, e.g. "2.50"
+func (a Amount) String() string {
+	sign := ""
+	if a < 0 {
+		sign = "-"
+		a = -a
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, int64(a)/100, int64(a)%100)
+}
+
+// MarshalJSON writes the amount as a decimal number without trailing zeros
+func (a Amount) MarshalJSON() ([]byte, error) {
+	s := a.String()
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || s == "-" {
+		s = "0"
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalJSON reads a decimal number or a quoted decimal string
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" || s == "" {
+		*a = 0
+		return nil
+	}
+	// Exponent notation only comes from clients formatting floats; it is still exact enough
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return ErrInvalidAmount
+		}
+		*a = FromFloat(f)
+		return nil
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Scan reads minor units from the database. Floats are accepted for rows
+// written before amounts were stored as integers.
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+	case int64:
+		*a = Amount(v)
+	case float64:
+		*a = Amount(math.Round(v))
+	case []byte:
+		return a.scanText(string(v))
+	case string:
+		return a.scanText(v)
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", src)
+	}
+	return nil
+}
+
+func (a *Amount) scanText(s string) error {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*a = Amount(math.Round(n))
+	return nil
+}
+
+// Value stores the amount as an integer number of minor units
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
+
+// MulQty multiplies a unit price by a (possibly fractional) quantity
+func (a Amount) MulQty(qty float64) Amount {
+	return Amount(math.Round(float64(a) * qty))
+}
+
+// Percent returns p percent of the amount
+func (a Amount) Percent(p float64) Amount {
+	return Amount(math.Round(float64(a) * p / 100))
+}
+
+// RoundTo rounds to the nearest multiple of step, halves away from zero.
+// A step of 0 or less leaves the amount unchanged.
+func (a Amount) RoundTo(step Amount) Amount {
+	if step <= 0 {
+		return a
+	}
+	if a < 0 {
+		return -(-a).RoundTo(step)
+	}
+	return (a + step/2) / step * step
+}
+
+// Allocate splits total over weights in proportion, with the leftover minor
+// units going to the largest remainders, so the parts always sum to total.
+// Negative totals and weights get nothing.
+func Allocate(total Amount, weights []Amount) []Amount {
+	parts := make([]Amount, len(weights))
+	sum := Amount(0)
+	for _, w := range weights {
+		if w > 0 {
+			sum += w
+		}
+	}
+	if sum == 0 || total <= 0 {
+		return parts
+	}
+
+	remainders := make([]float64, len(weights))
+	given := Amount(0)
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		exact := float64(total) * float64(w) / float64(sum)
+		parts[i] = Amount(math.Floor(exact))
+		remainders[i] = exact - math.Floor(exact)
+		given += parts[i]
+	}
+
+	for left := total - given; left > 0; left-- {
+		best := -1
+		for i, r := range remainders {
+			if weights[i] > 0 && (best == -1 || r > remainders[best]) {
+				best = i
+			}
+		}
+		parts[best]++
+		remainders[best] = -1
+	}
+	return parts
+}
@@ -0,0 +1,82 @@
+// Package phone normalizes phone numbers to E.164 so the same customer is not
+// stored twice as "0812-3456-7890" and "+62 812 3456 7890".
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+// DefaultCountryCode is the calling code assumed for numbers written in national format
+const DefaultCountryCode = "62"
+
+// ErrInvalid is returned for text that cannot be a phone number
+var ErrInvalid = errors.New("invalid phone number")
+
+// Normalize returns the number in E.164 form, e.g. "+6281234567890".
+// Spaces, dashes, dots and brackets are ignored. A leading "00" is an
+// international prefix. Other numbers are read in the country of countryCode,
+// with or without their trunk "0" or the country code itself.
+func Normalize(raw, countryCode string) (string, error) {
+	number, international, err := digits(raw)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case international:
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case strings.HasPrefix(number, "0"):
+		number = countryCode + number[1:]
+	case strings.HasPrefix(number, countryCode):
+	default:
+		// Written without the trunk 0, as Indonesian mobiles often are ("812...")
+		number = countryCode + number
+	}
+
+	// E.164 allows at most 15 digits; anything under 8 is a typo
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", ErrInvalid
+	}
+	return "+" + number, nil
+}
+
+// SearchTerm returns the digits to look for in stored E.164 numbers when searching
+// for raw, which may be only part of a number: "0812" becomes "62812". ok is false
+// when raw is not a phone number. Without a trunk 0 or international prefix the
+// digits could be anywhere in the number and are searched as typed.
+func SearchTerm(raw, countryCode string) (string, bool) {
+	number, international, err := digits(raw)
+	if err != nil || number == "" {
+		return "", false
+	}
+
+	switch {
+	case international:
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case strings.HasPrefix(number, "0"):
+		number = countryCode + number[1:]
+	}
+	return number, true
+}
+
+// digits strips the separators from a phone number and reports whether it was
+// written with a leading "+"
+func digits(raw string) (string, bool, error) {
+	international := false
+	number := make([]byte, 0, len(raw))
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			number = append(number, byte(r))
+		case r == '+' && i == 0:
+			international = true
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", false, ErrInvalid
+		}
+	}
+	return string(number), international, nil
+}